@@ -4,5 +4,5 @@ import (
 	"embed"
 )
 
-//go:embed "migrations"
+//go:embed "geo" "migrations" "openapi"
 var EmbeddedFiles embed.FS
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS courses_rating_avg_idx;
+ALTER TABLE courses DROP COLUMN IF EXISTS rating_count;
+ALTER TABLE courses DROP COLUMN IF EXISTS rating_avg;
+DROP TABLE IF EXISTS reviews;
\ No newline at end of file
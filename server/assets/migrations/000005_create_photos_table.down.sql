@@ -0,0 +1 @@
+DROP TABLE IF EXISTS photos;
\ No newline at end of file
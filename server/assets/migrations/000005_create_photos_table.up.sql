@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS photos (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1,
+    course_id bigint NOT NULL REFERENCES courses ON DELETE CASCADE,
+    position integer NOT NULL,
+    caption text NOT NULL DEFAULT '',
+    content_type text NOT NULL,
+    size_bytes bigint NOT NULL,
+    width integer NOT NULL,
+    height integer NOT NULL,
+    key_prefix text NOT NULL,
+    extension text NOT NULL
+);
+CREATE INDEX IF NOT EXISTS photos_course_id_position_idx ON photos (course_id, position);
\ No newline at end of file
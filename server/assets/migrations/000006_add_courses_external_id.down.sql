@@ -0,0 +1 @@
+ALTER TABLE courses DROP COLUMN IF EXISTS external_id;
\ No newline at end of file
@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS courses_record_tombstone ON courses;
+DROP TRIGGER IF EXISTS courses_set_change_seq ON courses;
+DROP FUNCTION IF EXISTS courses_record_tombstone();
+DROP FUNCTION IF EXISTS courses_set_change_seq();
+DROP FUNCTION IF EXISTS courses_next_change_seq();
+DROP TABLE IF EXISTS course_tombstones;
+ALTER TABLE courses DROP COLUMN IF EXISTS change_seq;
+DROP SEQUENCE IF EXISTS courses_change_seq;
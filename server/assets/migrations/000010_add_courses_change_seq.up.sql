@@ -0,0 +1,48 @@
+CREATE SEQUENCE IF NOT EXISTS courses_change_seq;
+
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS change_seq bigint NOT NULL DEFAULT nextval('courses_change_seq');
+
+CREATE INDEX IF NOT EXISTS courses_change_seq_idx ON courses (change_seq);
+
+CREATE TABLE IF NOT EXISTS course_tombstones (
+    course_id bigint PRIMARY KEY,
+    change_seq bigint NOT NULL,
+    deleted_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS course_tombstones_change_seq_idx ON course_tombstones (change_seq);
+
+-- Change numbers are handed out under a transaction-level lock, so they're in
+-- commit order: a reader that has seen change N can never later find an
+-- uncommitted change below N appear. Course writes are rare enough that
+-- serialising them costs nothing noticeable.
+CREATE OR REPLACE FUNCTION courses_next_change_seq() RETURNS bigint AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('courses_change_seq'));
+    RETURN nextval('courses_change_seq');
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION courses_set_change_seq() RETURNS trigger AS $$
+BEGIN
+    NEW.change_seq := courses_next_change_seq();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION courses_record_tombstone() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO course_tombstones (course_id, change_seq)
+    VALUES (OLD.id, courses_next_change_seq())
+    ON CONFLICT (course_id) DO UPDATE SET change_seq = EXCLUDED.change_seq, deleted_at = NOW();
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER courses_set_change_seq
+    BEFORE INSERT OR UPDATE ON courses
+    FOR EACH ROW EXECUTE FUNCTION courses_set_change_seq();
+
+CREATE TRIGGER courses_record_tombstone
+    AFTER DELETE ON courses
+    FOR EACH ROW EXECUTE FUNCTION courses_record_tombstone();
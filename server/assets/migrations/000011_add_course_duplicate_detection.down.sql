@@ -0,0 +1,5 @@
+DELETE FROM permissions WHERE code = 'courses:moderate';
+DROP FUNCTION IF EXISTS distance_box(point, double precision);
+DROP FUNCTION IF EXISTS haversine_distance(point, point);
+DROP INDEX IF EXISTS courses_location_idx;
+DROP EXTENSION IF EXISTS pg_trgm;
@@ -0,0 +1,26 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS courses_location_idx ON courses USING GIST (location);
+
+-- Great-circle distance in metres between two (longitude, latitude) points.
+CREATE OR REPLACE FUNCTION haversine_distance(a point, b point) RETURNS double precision AS $$
+    SELECT 2 * 6371000 * asin(least(1, sqrt(
+        power(sin(radians(b[1] - a[1]) / 2), 2) +
+        cos(radians(a[1])) * cos(radians(b[1])) * power(sin(radians(b[0] - a[0]) / 2), 2)
+    )))
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+-- A box around a point that contains everything within the given number of
+-- metres, so the location index can narrow down candidates before the exact
+-- distance is worked out. Longitude degrees shrink towards the poles, hence
+-- the cosine, which is clamped so the box stays finite.
+CREATE OR REPLACE FUNCTION distance_box(p point, metres double precision) RETURNS box AS $$
+    SELECT box(
+        point(p[0] - metres / (111320 * greatest(cos(radians(p[1])), 0.01)), p[1] - metres / 111320),
+        point(p[0] + metres / (111320 * greatest(cos(radians(p[1])), 0.01)), p[1] + metres / 111320)
+    )
+$$ LANGUAGE sql IMMUTABLE STRICT;
+
+INSERT INTO permissions (code)
+VALUES ('courses:moderate')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1 @@
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS merged_into bigint REFERENCES courses ON DELETE SET NULL;
@@ -0,0 +1,19 @@
+-- Everything already in the table was added by us, so it's all live.
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'pending_review', 'published', 'rejected'));
+
+CREATE INDEX IF NOT EXISTS courses_status_idx ON courses (status, created_at) WHERE status <> 'published';
+
+-- The audit trail outlives the courses it's about, so course_id isn't a
+-- foreign key.
+CREATE TABLE IF NOT EXISTS moderation_decisions (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    course_id bigint NOT NULL,
+    moderator_id bigint REFERENCES users ON DELETE SET NULL,
+    decision text NOT NULL,
+    from_status text NOT NULL,
+    reason text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS moderation_decisions_course_id_idx ON moderation_decisions (course_id, id);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS course_suggestions (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    course_id bigint NOT NULL REFERENCES courses ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    base_version integer NOT NULL,
+    changes jsonb NOT NULL,
+    comment text NOT NULL DEFAULT '',
+    status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'rejected')),
+    reviewed_at timestamp(0) with time zone,
+    reviewer_id bigint REFERENCES users ON DELETE SET NULL,
+    review_reason text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS course_suggestions_course_id_idx ON course_suggestions (course_id, id);
+CREATE INDEX IF NOT EXISTS course_suggestions_user_id_idx ON course_suggestions (user_id, id);
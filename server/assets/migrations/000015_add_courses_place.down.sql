@@ -0,0 +1,2 @@
+ALTER TABLE courses DROP COLUMN IF EXISTS region;
+ALTER TABLE courses DROP COLUMN IF EXISTS country_code;
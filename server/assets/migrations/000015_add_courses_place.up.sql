@@ -0,0 +1,6 @@
+-- Both stay NULL until the course has been looked up in the boundary
+-- dataset. Courses outside every boundary get empty strings instead.
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS country_code text;
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS region text;
+
+CREATE INDEX IF NOT EXISTS courses_country_code_region_idx ON courses (country_code, region);
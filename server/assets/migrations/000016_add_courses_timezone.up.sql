@@ -0,0 +1,6 @@
+-- timezone stays NULL until the course has been looked up, and is empty for
+-- courses outside every timezone boundary. A manual timezone is kept when the
+-- location changes.
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS timezone text;
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS timezone_source text NOT NULL DEFAULT 'location'
+    CHECK (timezone_source IN ('location', 'manual'));
@@ -0,0 +1,6 @@
+ALTER TABLE courses DROP COLUMN IF EXISTS is_certified;
+ALTER TABLE courses DROP COLUMN IF EXISTS terrain_difficulty;
+ALTER TABLE courses DROP COLUMN IF EXISTS laps;
+ALTER TABLE courses DROP COLUMN IF EXISTS elevation_gain_m;
+ALTER TABLE courses DROP COLUMN IF EXISTS surface;
+ALTER TABLE courses DROP COLUMN IF EXISTS distance_m;
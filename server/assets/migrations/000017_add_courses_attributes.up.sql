@@ -0,0 +1,11 @@
+-- Unknown attributes are NULL, so that a course with no recorded distance
+-- doesn't match every distance filter as 0 would.
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS distance_m integer CHECK (distance_m > 0);
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS surface text CHECK (surface IN ('road', 'trail', 'grass', 'track', 'mixed'));
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS elevation_gain_m integer CHECK (elevation_gain_m >= 0);
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS laps integer CHECK (laps > 0);
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS terrain_difficulty smallint CHECK (terrain_difficulty BETWEEN 1 AND 5);
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS is_certified boolean NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS courses_distance_m_idx ON courses (distance_m);
+CREATE INDEX IF NOT EXISTS courses_surface_distance_m_idx ON courses (surface, distance_m);
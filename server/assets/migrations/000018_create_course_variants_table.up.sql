@@ -0,0 +1,17 @@
+-- start_time is local to the course's timezone, so it has no zone of its own.
+CREATE TABLE IF NOT EXISTS course_variants (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1,
+    course_id bigint NOT NULL REFERENCES courses ON DELETE CASCADE,
+    name text NOT NULL,
+    distance_m integer CHECK (distance_m > 0),
+    route jsonb,
+    days text[] NOT NULL DEFAULT '{}',
+    start_time time(0),
+    schedule_notes text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS course_variants_course_id_idx ON course_variants (course_id, id);
+CREATE INDEX IF NOT EXISTS course_variants_distance_m_idx ON course_variants (distance_m, course_id);
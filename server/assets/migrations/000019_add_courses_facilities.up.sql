@@ -0,0 +1,5 @@
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS facilities jsonb NOT NULL DEFAULT '{}';
+
+-- Facility filters are containment tests like facilities @> '{"parking": "yes"}',
+-- which jsonb_path_ops indexes compactly.
+CREATE INDEX IF NOT EXISTS courses_facilities_idx ON courses USING gin (facilities jsonb_path_ops);
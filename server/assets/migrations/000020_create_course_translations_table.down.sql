@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS courses_name_english_idx;
+CREATE INDEX IF NOT EXISTS courses_name_idx ON courses USING GIN (to_tsvector('simple', name));
+
+DROP TABLE IF EXISTS course_translations;
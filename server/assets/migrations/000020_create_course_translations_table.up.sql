@@ -0,0 +1,18 @@
+-- Courses' own names and descriptions are in the default locale, English.
+-- Translations carry the text search configuration for their language, so
+-- each is searched with the right stemming.
+CREATE TABLE IF NOT EXISTS course_translations (
+    course_id bigint NOT NULL REFERENCES courses ON DELETE CASCADE,
+    locale text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    name text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    search_config regconfig NOT NULL DEFAULT 'simple',
+    PRIMARY KEY (course_id, locale)
+);
+
+CREATE INDEX IF NOT EXISTS course_translations_name_idx ON course_translations USING GIN (to_tsvector(search_config, name));
+
+DROP INDEX IF EXISTS courses_name_idx;
+CREATE INDEX IF NOT EXISTS courses_name_english_idx ON courses USING GIN (to_tsvector('english', name));
@@ -0,0 +1,4 @@
+ALTER TABLE courses DROP COLUMN IF EXISTS organisation_id;
+
+DROP TABLE IF EXISTS organisation_members;
+DROP TABLE IF EXISTS organisations;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS organisations (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    version integer NOT NULL DEFAULT 1,
+    name text NOT NULL,
+    website text NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS organisation_members (
+    organisation_id bigint NOT NULL REFERENCES organisations ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    role text NOT NULL CHECK (role IN ('owner', 'editor')),
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organisation_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS organisation_members_user_id_idx ON organisation_members (user_id);
+
+-- An organisation can't be deleted while it still runs courses, as that
+-- would quietly take them out of its members' hands and leave them to the
+-- moderators.
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS organisation_id bigint REFERENCES organisations ON DELETE RESTRICT;
+
+CREATE INDEX IF NOT EXISTS courses_organisation_id_idx ON courses (organisation_id, id);
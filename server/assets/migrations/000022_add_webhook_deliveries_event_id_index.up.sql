@@ -0,0 +1,3 @@
+-- Pruning old events from the outbox deletes their deliveries too, which
+-- needs to find them by event.
+CREATE INDEX IF NOT EXISTS webhook_deliveries_event_id_idx ON webhook_deliveries (event_id);
@@ -0,0 +1,5 @@
+-- Who submitted a course, so they can follow it through moderation. Kept
+-- when they delete their account, but no longer linked to anyone.
+ALTER TABLE courses ADD COLUMN IF NOT EXISTS submitted_by bigint REFERENCES users ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS courses_submitted_by_idx ON courses (submitted_by, id) WHERE submitted_by IS NOT NULL;
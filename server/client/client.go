@@ -0,0 +1,302 @@
+// Package client is a typed Go client for the runda API.
+//
+//	c := client.New("https://api.example.com", client.WithToken(token))
+//	course, err := c.GetCourse(ctx, 42)
+//	if errors.Is(err, client.ErrNotFound) {
+//		...
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+	language   string
+	maxRetries int
+	retryWait  time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests. The default is a
+// client with a 30 second timeout.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithToken authenticates every request with a token from
+// POST /v1/tokens/authentication.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithLanguage sends an Accept-Language header with every request, such as
+// "fr-CA,fr;q=0.9", so that courses come back translated where possible.
+func WithLanguage(language string) Option {
+	return func(c *Client) {
+		c.language = language
+	}
+}
+
+// WithRetries sets how many times idempotent requests are retried after a
+// network error or a 429, 502, 503 or 504 response, and the wait before the
+// first retry, which doubles on each attempt. The default is 3 retries
+// starting at 200ms. Pass 0 retries to disable them.
+func WithRetries(maxRetries int, wait time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryWait = wait
+	}
+}
+
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: 3,
+		retryWait:  200 * time.Millisecond,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// do sends a request and decodes a successful JSON response into out, which
+// may be nil. Error responses are returned as an *APIError.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, header http.Header, in, out any) error {
+	var body []byte
+
+	if in != nil {
+		var err error
+
+		body, err = json.Marshal(in)
+		if err != nil {
+			return err
+		}
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	attempts := 1
+	if isIdempotent(method) {
+		attempts += c.maxRetries
+	}
+
+	var err error
+
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			err := sleep(ctx, backoff(c.retryWait, attempt))
+			if err != nil {
+				return err
+			}
+		}
+
+		var retry bool
+
+		retry, err = c.attempt(ctx, method, u, header, body, out)
+		if !retry {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (c *Client) attempt(ctx context.Context, method, u string, header http.Header, body []byte, out any) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.language != "" && req.Header.Get("Accept-Language") == "" {
+		req.Header.Set("Accept-Language", c.language)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		// Don't retry once the caller has given up.
+		return ctx.Err() == nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return isRetryableStatus(res.StatusCode), decodeError(res)
+	}
+
+	if out == nil {
+		_, err = io.Copy(io.Discard, res.Body)
+		return false, err
+	}
+
+	err = json.NewDecoder(res.Body).Decode(out)
+	if err != nil {
+		return false, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return false, nil
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff doubles the wait for each attempt, with up to 50% jitter so that
+// many clients retrying at once spread out.
+func backoff(wait time.Duration, attempt int) time.Duration {
+	d := wait << (attempt - 1)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrEditConflict = errors.New("edit conflict")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+)
+
+// editConflictMessage starts the message the API sends with a 409 when a
+// record has changed since it was read, as opposed to other conflicts such as
+// a duplicate external_id or a course that's already archived.
+const editConflictMessage = "unable to update the record due to an edit conflict"
+
+// APIError is returned for every error response from the API. Use errors.Is
+// with ErrNotFound, ErrConflict, ErrEditConflict, ErrValidation,
+// ErrUnauthorized or ErrForbidden to check what kind of error it is. Every
+// error matching ErrEditConflict also matches ErrConflict.
+type APIError struct {
+	StatusCode int
+	Message    string
+	RequestID  string
+	// Errors holds any detail the API sent alongside the message, such as
+	// the per-row errors of an import.
+	Errors []json.RawMessage
+	// Duplicates lists the existing courses a new course looks like, when
+	// CreateCourse fails because of them.
+	Duplicates []DuplicateCandidate
+}
+
+func (e *APIError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("runda: %d %s (request %s)", e.StatusCode, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("runda: %d %s", e.StatusCode, e.Message)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrEditConflict:
+		return e.StatusCode == http.StatusConflict && strings.HasPrefix(e.Message, editConflictMessage)
+	case ErrValidation:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	default:
+		return false
+	}
+}
+
+func decodeError(res *http.Response) error {
+	apiErr := &APIError{
+		StatusCode: res.StatusCode,
+		Message:    http.StatusText(res.StatusCode),
+		RequestID:  res.Header.Get("X-Request-ID"),
+	}
+
+	var envelope struct {
+		Message    json.RawMessage      `json:"message"`
+		RequestID  string               `json:"request_id"`
+		Errors     []json.RawMessage    `json:"errors"`
+		Duplicates []DuplicateCandidate `json:"duplicates"`
+	}
+
+	err := json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&envelope)
+	if err != nil {
+		return apiErr
+	}
+
+	var message string
+	if json.Unmarshal(envelope.Message, &message) == nil {
+		apiErr.Message = message
+	} else if len(envelope.Message) > 0 {
+		apiErr.Message = string(envelope.Message)
+	}
+
+	if envelope.RequestID != "" {
+		apiErr.RequestID = envelope.RequestID
+	}
+
+	apiErr.Errors = envelope.Errors
+	apiErr.Duplicates = envelope.Duplicates
+
+	return apiErr
+}
@@ -0,0 +1,564 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Coords struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type Course struct {
+	ID            int64      `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastUpdatedAt time.Time  `json:"last_updated_at"`
+	Version       int32      `json:"version"`
+	Name          string     `json:"name"`
+	Description   string     `json:"description,omitempty"`
+	Location      Coords     `json:"location"`
+	Tags          []string   `json:"tags"`
+	Website       string     `json:"website,omitempty"`
+	RatingAvg     float64    `json:"rating_avg"`
+	RatingCount   int        `json:"rating_count"`
+	ExternalID    string     `json:"external_id,omitempty"`
+	ArchivedAt    *time.Time `json:"archived_at,omitempty"`
+	MergedInto    *int64     `json:"merged_into,omitempty"`
+	Status        string     `json:"status"`
+	// CountryCode and Region are looked up by the API from the location.
+	CountryCode string `json:"country_code,omitempty"`
+	Region      string `json:"region,omitempty"`
+	// Timezone is looked up from the location too, unless TimezoneSource is
+	// TimezoneManual. UTCOffset is its offset when the course was fetched.
+	Timezone       string `json:"timezone,omitempty"`
+	TimezoneSource string `json:"timezone_source,omitempty"`
+	UTCOffset      string `json:"utc_offset,omitempty"`
+	CourseAttributes
+	Facilities Facilities `json:"facilities"`
+	// Locale is the language of Name and Description, which come from a
+	// translation if the course has one in the requested language.
+	Locale string `json:"locale,omitempty"`
+	// OrganisationID is the organisation that runs the course, whose members
+	// are the only ones who can change it.
+	OrganisationID *int64 `json:"organisation_id,omitempty"`
+}
+
+// CourseAttributes describe the route. Nil fields aren't known.
+type CourseAttributes struct {
+	DistanceM         *int   `json:"distance_m,omitempty"`
+	Surface           string `json:"surface,omitempty"`
+	ElevationGainM    *int   `json:"elevation_gain_m,omitempty"`
+	Laps              *int   `json:"laps,omitempty"`
+	TerrainDifficulty *int   `json:"terrain_difficulty,omitempty"`
+	IsCertified       bool   `json:"is_certified"`
+}
+
+// Facilities describes what's at and around a course. Each facility is
+// FacilityYes, FacilityNo or FacilityUnknown. In a CourseInput or
+// CoursePatch, blank facilities are left unknown or unchanged.
+type Facilities struct {
+	Wheelchair string `json:"wheelchair,omitempty"`
+	Buggy      string `json:"buggy,omitempty"`
+	Dogs       string `json:"dogs,omitempty"`
+	Toilets    string `json:"toilets,omitempty"`
+	Parking    string `json:"parking,omitempty"`
+	Showers    string `json:"showers,omitempty"`
+	Cafe       string `json:"cafe,omitempty"`
+	// Station is whether a railway or bus station is within walking
+	// distance.
+	Station        string `json:"station,omitempty"`
+	BikeRacks      string `json:"bike_racks,omitempty"`
+	NearestStation string `json:"nearest_station,omitempty"`
+}
+
+const (
+	FacilityYes     = "yes"
+	FacilityNo      = "no"
+	FacilityUnknown = "unknown"
+)
+
+// Course surfaces.
+const (
+	SurfaceRoad  = "road"
+	SurfaceTrail = "trail"
+	SurfaceGrass = "grass"
+	SurfaceTrack = "track"
+	SurfaceMixed = "mixed"
+)
+
+// Timezone sources, and the timezone to send to switch from a manual
+// timezone back to looking it up.
+const (
+	TimezoneFromLocation = "location"
+	TimezoneManual       = "manual"
+	TimezoneAuto         = "auto"
+)
+
+// Course statuses. Only published courses are visible to everyone.
+const (
+	StatusDraft         = "draft"
+	StatusPendingReview = "pending_review"
+	StatusPublished     = "published"
+	StatusRejected      = "rejected"
+)
+
+type Metadata struct {
+	CurrentPage  int `json:"current_page,omitempty"`
+	PageSize     int `json:"page_size,omitempty"`
+	FirstPage    int `json:"first_page,omitempty"`
+	LastPage     int `json:"last_page,omitempty"`
+	TotalRecords int `json:"total_records,omitempty"`
+}
+
+// CourseInput is the body of a new course.
+type CourseInput struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Location    Coords   `json:"location"`
+	Tags        []string `json:"tags,omitempty"`
+	Website     string   `json:"website,omitempty"`
+	ExternalID  string   `json:"external_id,omitempty"`
+	// Timezone overrides the one looked up from the location.
+	Timezone string `json:"timezone,omitempty"`
+	CourseAttributes
+	Facilities *Facilities `json:"facilities,omitempty"`
+	// OrganisationID gives the course to an organisation the user is a
+	// member of.
+	OrganisationID *int64 `json:"organisation_id,omitempty"`
+	// Status is only honoured for moderators. Other users' courses are
+	// always held for review.
+	Status string `json:"status,omitempty"`
+	// Force creates the course even if it looks like a duplicate of an
+	// existing one.
+	Force bool `json:"-"`
+}
+
+// DuplicateCandidate is an existing course that a new one looks like.
+type DuplicateCandidate struct {
+	Course     Course  `json:"course"`
+	Distance   float64 `json:"distance_metres"`
+	Similarity float64 `json:"similarity"`
+}
+
+// CoursePatch holds the fields to change on a course. Nil fields are left
+// as they are.
+type CoursePatch struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Location    *Coords `json:"location,omitempty"`
+	// Tags replaces the course's tags. Point it at an empty slice to
+	// clear them.
+	Tags    *[]string `json:"tags,omitempty"`
+	Website *string   `json:"website,omitempty"`
+	// Timezone sets the course's timezone by hand, or with TimezoneAuto,
+	// goes back to looking it up from the location.
+	Timezone *string `json:"timezone,omitempty"`
+
+	DistanceM         *int    `json:"distance_m,omitempty"`
+	Surface           *string `json:"surface,omitempty"`
+	ElevationGainM    *int    `json:"elevation_gain_m,omitempty"`
+	Laps              *int    `json:"laps,omitempty"`
+	TerrainDifficulty *int    `json:"terrain_difficulty,omitempty"`
+	// IsCertified can't be suggested with SuggestEdit.
+	IsCertified *bool       `json:"is_certified,omitempty"`
+	Facilities  *Facilities `json:"facilities,omitempty"`
+	// OrganisationID moves the course to another organisation the user is
+	// a member of.
+	OrganisationID *int64 `json:"organisation_id,omitempty"`
+}
+
+// Sort keys for ListCoursesOptions. Prefix with "-" to sort descending.
+const (
+	SortByID            = "id"
+	SortByName          = "name"
+	SortByRating        = "rating"
+	SortByDistance      = "distance"
+	SortByElevationGain = "elevation_gain"
+)
+
+// ListCoursesOptions are the filters of GET /v1/courses. Zero values are
+// left out of the request, so the API's defaults apply.
+type ListCoursesOptions struct {
+	Name      string
+	Tags      []string
+	MinRating float64
+	// Country is an ISO 3166-1 alpha-2 code.
+	Country string
+	Region  string
+	// The ranges are inclusive. Int and Bool make setting these easier.
+	DistanceMin, DistanceMax                   *int
+	ElevationGainMin, ElevationGainMax         *int
+	TerrainDifficultyMin, TerrainDifficultyMax *int
+	// Surfaces matches courses with any of them.
+	Surfaces  []string
+	Certified *bool
+	// The variant range matches courses with a variant inside it, and
+	// still lists the courses themselves.
+	VariantDistanceMin, VariantDistanceMax *int
+	// Facilities matches courses known to have all of them, by their JSON
+	// names, such as "wheelchair".
+	Facilities []string
+	// Lang overrides the client's WithLanguage for this listing, as a single
+	// language tag.
+	Lang string
+	// Organisation matches the courses run by the organisation with this ID.
+	Organisation int64
+
+	Page     int
+	PageSize int
+	Sort     string
+}
+
+// Int returns a pointer to i, for the optional fields of requests.
+func Int(i int) *int {
+	return &i
+}
+
+// Bool returns a pointer to b, for the optional fields of requests.
+func Bool(b bool) *bool {
+	return &b
+}
+
+func (o ListCoursesOptions) values() url.Values {
+	q := url.Values{}
+
+	if o.Name != "" {
+		q.Set("name", o.Name)
+	}
+	if len(o.Tags) > 0 {
+		q.Set("tags", strings.Join(o.Tags, ","))
+	}
+	if o.MinRating > 0 {
+		q.Set("min_rating", strconv.FormatFloat(o.MinRating, 'f', -1, 64))
+	}
+	if o.Country != "" {
+		q.Set("country", o.Country)
+	}
+	if o.Region != "" {
+		q.Set("region", o.Region)
+	}
+
+	ranges := []struct {
+		key   string
+		value *int
+	}{
+		{"distance_min", o.DistanceMin},
+		{"distance_max", o.DistanceMax},
+		{"elevation_gain_min", o.ElevationGainMin},
+		{"elevation_gain_max", o.ElevationGainMax},
+		{"terrain_difficulty_min", o.TerrainDifficultyMin},
+		{"terrain_difficulty_max", o.TerrainDifficultyMax},
+		{"variant_distance_min", o.VariantDistanceMin},
+		{"variant_distance_max", o.VariantDistanceMax},
+	}
+
+	for _, r := range ranges {
+		if r.value != nil {
+			q.Set(r.key, strconv.Itoa(*r.value))
+		}
+	}
+
+	if len(o.Surfaces) > 0 {
+		q.Set("surface", strings.Join(o.Surfaces, ","))
+	}
+	if len(o.Facilities) > 0 {
+		q.Set("facilities", strings.Join(o.Facilities, ","))
+	}
+	if o.Lang != "" {
+		q.Set("lang", o.Lang)
+	}
+	if o.Organisation > 0 {
+		q.Set("organisation", strconv.FormatInt(o.Organisation, 10))
+	}
+	if o.Certified != nil {
+		q.Set("certified", strconv.FormatBool(*o.Certified))
+	}
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PageSize > 0 {
+		q.Set("page_size", strconv.Itoa(o.PageSize))
+	}
+	if o.Sort != "" {
+		q.Set("sort", o.Sort)
+	}
+
+	return q
+}
+
+func (c *Client) ListCourses(ctx context.Context, opts ListCoursesOptions) ([]Course, Metadata, error) {
+	var out struct {
+		Courses  []Course `json:"courses"`
+		Metadata Metadata `json:"metadata"`
+	}
+
+	err := c.do(ctx, http.MethodGet, "/v1/courses", opts.values(), nil, nil, &out)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return out.Courses, out.Metadata, nil
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	// CountryCode is the country a region is in.
+	CountryCode string `json:"country_code,omitempty"`
+	Count       int    `json:"count"`
+}
+
+// CourseFacets counts courses by place, most common first.
+type CourseFacets struct {
+	Country []FacetCount `json:"country"`
+	Region  []FacetCount `json:"region"`
+}
+
+// ListCourseFacets counts the courses matching opts by country and by region.
+// Each count ignores its own filter, so with opts.Country set, Country still
+// lists every country the other filters match. Paging and sorting are
+// ignored.
+func (c *Client) ListCourseFacets(ctx context.Context, opts ListCoursesOptions) (*CourseFacets, error) {
+	var out struct {
+		Facets CourseFacets `json:"facets"`
+	}
+
+	opts.Page, opts.PageSize, opts.Sort = 0, 1, ""
+
+	err := c.do(ctx, http.MethodGet, "/v1/courses", opts.values(), nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Facets, nil
+}
+
+func (c *Client) GetCourse(ctx context.Context, id int64) (*Course, error) {
+	var out struct {
+		Course Course `json:"course"`
+	}
+
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/courses/%d", id), nil, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Course, nil
+}
+
+// CreateCourse adds a course. If it looks like a duplicate of an existing
+// course, it fails with an *APIError matching ErrConflict, whose Duplicates
+// lists the matches; set input.Force to create it anyway. It needs a token,
+// and unless the token belongs to a moderator, the course comes back in
+// StatusPendingReview and isn't visible until it's approved; follow it with
+// GetSubmission.
+func (c *Client) CreateCourse(ctx context.Context, input CourseInput) (*Course, error) {
+	var out struct {
+		Course Course `json:"course"`
+	}
+
+	q := url.Values{}
+	if input.Force {
+		q.Set("force", "true")
+	}
+
+	err := c.do(ctx, http.MethodPost, "/v1/courses", q, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Course, nil
+}
+
+// UpdateCourse applies patch to a course. If expectedVersion isn't zero, the
+// update is only made if the course is still at that version, and an error
+// matching ErrEditConflict is returned otherwise. Concurrent edits also
+// return ErrEditConflict, in which case fetch the course again and reapply
+// the change.
+func (c *Client) UpdateCourse(ctx context.Context, id int64, expectedVersion int32, patch CoursePatch) (*Course, error) {
+	var out struct {
+		Course Course `json:"course"`
+	}
+
+	header := http.Header{}
+	if expectedVersion != 0 {
+		header.Set("X-Expected-Version", strconv.FormatInt(int64(expectedVersion), 10))
+	}
+
+	err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/v1/courses/%d", id), nil, header, patch, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Course, nil
+}
+
+// ArchiveCourse archives a course, which leaves it out of listings and
+// exports. As with UpdateCourse, a non-zero expectedVersion makes it fail with
+// ErrEditConflict if the course has changed. A course that's already archived
+// fails with ErrConflict instead.
+func (c *Client) ArchiveCourse(ctx context.Context, id int64, expectedVersion int32) (*Course, error) {
+	var out struct {
+		Course Course `json:"course"`
+	}
+
+	header := http.Header{}
+	if expectedVersion != 0 {
+		header.Set("X-Expected-Version", strconv.FormatInt(int64(expectedVersion), 10))
+	}
+
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/v1/courses/%d/archive", id), nil, header, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Course, nil
+}
+
+// MergeInput says which course to merge and how. Strategy is one of
+// "target", "source" or "newest" and defaults to "target"; Fields overrides
+// it per field.
+type MergeInput struct {
+	SourceID int64             `json:"source_id"`
+	Strategy string            `json:"strategy,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+}
+
+// MergeCourse merges input.SourceID into the course id, which survives. The
+// source is archived, and GetCourse on it returns the survivor from then on.
+func (c *Client) MergeCourse(ctx context.Context, id int64, input MergeInput) (*Course, error) {
+	var out struct {
+		Course Course `json:"course"`
+	}
+
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/v1/courses/%d/merge", id), nil, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Course, nil
+}
+
+func (c *Client) DeleteCourse(ctx context.Context, id int64) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/v1/courses/%d", id), nil, nil, nil, nil)
+}
+
+// CourseIterator walks through every page of a course listing:
+//
+//	it := c.Courses(ctx, client.ListCoursesOptions{Tags: []string{"parkrun"}})
+//	for it.Next() {
+//		course := it.Course()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type CourseIterator struct {
+	client  *Client
+	ctx     context.Context
+	opts    ListCoursesOptions
+	page    []Course
+	index   int
+	current Course
+	done    bool
+	err     error
+}
+
+// Courses returns an iterator over every course matching opts, starting from
+// opts.Page (or the first page if unset).
+func (c *Client) Courses(ctx context.Context, opts ListCoursesOptions) *CourseIterator {
+	if opts.Page < 1 {
+		opts.Page = 1
+	}
+
+	return &CourseIterator{client: c, ctx: ctx, opts: opts}
+}
+
+func (it *CourseIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	for it.index >= len(it.page) {
+		if it.done {
+			return false
+		}
+
+		courses, metadata, err := it.client.ListCourses(it.ctx, it.opts)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.page = courses
+		it.index = 0
+		it.done = len(courses) == 0 || metadata.CurrentPage >= metadata.LastPage
+		it.opts.Page++
+	}
+
+	it.current = it.page[it.index]
+	it.index++
+
+	return true
+}
+
+// Course returns the course the last call to Next moved to.
+func (it *CourseIterator) Course() Course {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *CourseIterator) Err() error {
+	return it.err
+}
+
+// CourseChange is an entry in the change feed. Upserts carry the course as it
+// is now; deletes only carry its ID, and mean the course was deleted or
+// archived and should be dropped.
+type CourseChange struct {
+	Type       string  `json:"type"`
+	ID         int64   `json:"id"`
+	Reason     string  `json:"reason,omitempty"`
+	MergedInto *int64  `json:"merged_into,omitempty"`
+	Course     *Course `json:"course,omitempty"`
+}
+
+// Change types.
+const (
+	ChangeUpsert = "upsert"
+	ChangeDelete = "delete"
+)
+
+type ChangesPage struct {
+	Changes   []CourseChange `json:"changes"`
+	NextToken string         `json:"next_token"`
+	HasMore   bool           `json:"has_more"`
+}
+
+// Changes returns the courses changed since token, which is empty for a full
+// sync or the NextToken of an earlier page. Keep calling with NextToken while
+// HasMore is set; after that, save NextToken for the next sync. pageSize 0
+// uses the API's default.
+func (c *Client) Changes(ctx context.Context, token string, pageSize int) (*ChangesPage, error) {
+	q := url.Values{}
+	if token != "" {
+		q.Set("since", token)
+	}
+	if pageSize > 0 {
+		q.Set("page_size", strconv.Itoa(pageSize))
+	}
+
+	var page ChangesPage
+
+	err := c.do(ctx, http.MethodGet, "/v1/courses/changes", q, nil, nil, &page)
+	if err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
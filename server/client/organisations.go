@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Organisation is a club, council or other group that runs courses. Only
+// its members can change, archive or delete its courses.
+type Organisation struct {
+	ID            int64     `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastUpdatedAt time.Time `json:"last_updated_at"`
+	Version       int32     `json:"version"`
+	Name          string    `json:"name"`
+	Website       string    `json:"website,omitempty"`
+}
+
+type OrganisationInput struct {
+	Name    string `json:"name"`
+	Website string `json:"website,omitempty"`
+}
+
+// OrganisationPatch holds the fields to change on an organisation. Nil
+// fields are left as they are.
+type OrganisationPatch struct {
+	Name    *string `json:"name,omitempty"`
+	Website *string `json:"website,omitempty"`
+}
+
+// Organisation roles. Owners manage the organisation and its members, and
+// editors can only change its courses.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+)
+
+type Member struct {
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateOrganisation creates an organisation with the signed-in user as its
+// owner.
+func (c *Client) CreateOrganisation(ctx context.Context, input OrganisationInput) (*Organisation, error) {
+	var out struct {
+		Organisation Organisation `json:"organisation"`
+	}
+
+	err := c.do(ctx, http.MethodPost, "/v1/organisations", nil, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Organisation, nil
+}
+
+func (c *Client) GetOrganisation(ctx context.Context, id int64) (*Organisation, error) {
+	var out struct {
+		Organisation Organisation `json:"organisation"`
+	}
+
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/organisations/%d", id), nil, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Organisation, nil
+}
+
+// UpdateOrganisation applies patch to an organisation. Concurrent edits
+// return an error matching ErrEditConflict.
+func (c *Client) UpdateOrganisation(ctx context.Context, id int64, patch OrganisationPatch) (*Organisation, error) {
+	var out struct {
+		Organisation Organisation `json:"organisation"`
+	}
+
+	err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/v1/organisations/%d", id), nil, nil, patch, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Organisation, nil
+}
+
+// DeleteOrganisation deletes an organisation. It fails with an error
+// matching ErrConflict while the organisation still runs courses.
+func (c *Client) DeleteOrganisation(ctx context.Context, id int64) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/v1/organisations/%d", id), nil, nil, nil, nil)
+}
+
+// ListOrganisationCourses returns a page of the published courses an
+// organisation runs. Only opts' Lang, Page, PageSize and Sort are used; to
+// filter them further, set Organisation in ListCourses instead.
+func (c *Client) ListOrganisationCourses(ctx context.Context, id int64, opts ListCoursesOptions) ([]Course, Metadata, error) {
+	var out struct {
+		Courses  []Course `json:"courses"`
+		Metadata Metadata `json:"metadata"`
+	}
+
+	q := url.Values{}
+	if opts.Lang != "" {
+		q.Set("lang", opts.Lang)
+	}
+	if opts.Page > 0 {
+		q.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.PageSize > 0 {
+		q.Set("page_size", strconv.Itoa(opts.PageSize))
+	}
+	if opts.Sort != "" {
+		q.Set("sort", opts.Sort)
+	}
+
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/organisations/%d/courses", id), q, nil, nil, &out)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return out.Courses, out.Metadata, nil
+}
+
+func (c *Client) ListOrganisationMembers(ctx context.Context, id int64) ([]Member, error) {
+	var out struct {
+		Members []Member `json:"members"`
+	}
+
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/organisations/%d/members", id), nil, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Members, nil
+}
+
+// PutOrganisationMember adds a user to an organisation with the role, or
+// changes their role. Leaving the organisation without an owner returns an
+// error matching ErrConflict.
+func (c *Client) PutOrganisationMember(ctx context.Context, id, userID int64, role string) (*Member, error) {
+	var out struct {
+		Member Member `json:"member"`
+	}
+
+	input := struct {
+		Role string `json:"role"`
+	}{role}
+
+	err := c.do(ctx, http.MethodPut, fmt.Sprintf("/v1/organisations/%d/members/%d", id, userID), nil, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Member, nil
+}
+
+// DeleteOrganisationMember removes a user from an organisation. Like
+// PutOrganisationMember, it can't remove the last owner.
+func (c *Client) DeleteOrganisationMember(ctx context.Context, id, userID int64) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/v1/organisations/%d/members/%d", id, userID), nil, nil, nil, nil)
+}
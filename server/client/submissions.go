@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ModerationDecision is a moderator approving or rejecting a course.
+// ModeratorID is nil once the moderator's account is deleted.
+type ModerationDecision struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	CourseID    int64     `json:"course_id"`
+	ModeratorID *int64    `json:"moderator_id"`
+	Decision    string    `json:"decision"`
+	FromStatus  string    `json:"from_status"`
+	Reason      string    `json:"reason"`
+}
+
+// Submission is a course the signed-in user added, with the moderators'
+// decisions about it, newest first.
+type Submission struct {
+	Course    Course               `json:"course"`
+	Decisions []ModerationDecision `json:"decisions"`
+}
+
+// GetSubmission returns a course the signed-in user added, whatever its
+// status, so courses that CreateCourse left pending can be followed up.
+func (c *Client) GetSubmission(ctx context.Context, id int64) (*Submission, error) {
+	var out Submission
+
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/submissions/%d", id), nil, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// ListSubmissions returns a page of the courses the signed-in user added,
+// newest first. If status isn't empty, only courses in that status are
+// listed.
+func (c *Client) ListSubmissions(ctx context.Context, status string, page, pageSize int) ([]Course, Metadata, error) {
+	var out struct {
+		Courses  []Course `json:"courses"`
+		Metadata Metadata `json:"metadata"`
+	}
+
+	q := url.Values{}
+	if status != "" {
+		q.Set("status", status)
+	}
+	if page > 0 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	if pageSize > 0 {
+		q.Set("page_size", strconv.Itoa(pageSize))
+	}
+
+	err := c.do(ctx, http.MethodGet, "/v1/submissions", q, nil, nil, &out)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return out.Courses, out.Metadata, nil
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Suggestion statuses.
+const (
+	SuggestionPending  = "pending"
+	SuggestionAccepted = "accepted"
+	SuggestionRejected = "rejected"
+)
+
+// Suggestion is a proposed edit to a course, waiting for or past review.
+type Suggestion struct {
+	ID           int64       `json:"id"`
+	CreatedAt    time.Time   `json:"created_at"`
+	CourseID     int64       `json:"course_id"`
+	UserID       int64       `json:"user_id"`
+	BaseVersion  int32       `json:"base_version"`
+	Changes      CoursePatch `json:"changes"`
+	Comment      string      `json:"comment,omitempty"`
+	Status       string      `json:"status"`
+	ReviewedAt   *time.Time  `json:"reviewed_at,omitempty"`
+	ReviewerID   *int64      `json:"reviewer_id,omitempty"`
+	ReviewReason string      `json:"review_reason,omitempty"`
+	Diff         []FieldDiff `json:"diff,omitempty"`
+	Stale        bool        `json:"stale,omitempty"`
+}
+
+// FieldDiff is a field a suggestion would change, with its value now and the
+// suggested one.
+type FieldDiff struct {
+	Field    string          `json:"field"`
+	Current  json.RawMessage `json:"current"`
+	Proposed json.RawMessage `json:"proposed"`
+}
+
+// SuggestEdit proposes changes to a course for a moderator to review, for
+// users who can't update it themselves. Use GetSuggestion to follow it up.
+func (c *Client) SuggestEdit(ctx context.Context, courseID int64, changes CoursePatch, comment string) (*Suggestion, error) {
+	in := struct {
+		CoursePatch
+		Comment string `json:"comment,omitempty"`
+	}{changes, comment}
+
+	var out struct {
+		Suggestion Suggestion `json:"suggestion"`
+	}
+
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/v1/courses/%d/suggestions", courseID), nil, nil, in, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Suggestion, nil
+}
+
+func (c *Client) GetSuggestion(ctx context.Context, id int64) (*Suggestion, error) {
+	var out struct {
+		Suggestion Suggestion `json:"suggestion"`
+	}
+
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/suggestions/%d", id), nil, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Suggestion, nil
+}
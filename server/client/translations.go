@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Translation is a course's name and description in another locale, such as
+// "fr" or "pt-BR".
+type Translation struct {
+	CourseID      int64     `json:"course_id"`
+	Locale        string    `json:"locale"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastUpdatedAt time.Time `json:"last_updated_at"`
+	Name          string    `json:"name"`
+	Description   string    `json:"description,omitempty"`
+}
+
+type TranslationInput struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+func (c *Client) ListTranslations(ctx context.Context, courseID int64) ([]Translation, error) {
+	var out struct {
+		Translations []Translation `json:"translations"`
+	}
+
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/courses/%d/translations", courseID), nil, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Translations, nil
+}
+
+// PutTranslation adds the course's translation into locale, or replaces it.
+// This counts as an update to the course, so its version goes up, and if the
+// course is changed at the same time it fails with an error matching
+// ErrConflict.
+func (c *Client) PutTranslation(ctx context.Context, courseID int64, locale string, input TranslationInput) (*Translation, error) {
+	var out struct {
+		Translation Translation `json:"translation"`
+	}
+
+	err := c.do(ctx, http.MethodPut, fmt.Sprintf("/v1/courses/%d/translations/%s", courseID, url.PathEscape(locale)), nil, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Translation, nil
+}
+
+func (c *Client) DeleteTranslation(ctx context.Context, courseID int64, locale string) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/v1/courses/%d/translations/%s", courseID, url.PathEscape(locale)), nil, nil, nil, nil)
+}
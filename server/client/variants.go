@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Variant is one of the distances run from a course, such as a junior 2k
+// alongside the main 5k.
+type Variant struct {
+	ID            int64     `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastUpdatedAt time.Time `json:"last_updated_at"`
+	Version       int32     `json:"version"`
+	CourseID      int64     `json:"course_id"`
+	Name          string    `json:"name"`
+	DistanceM     *int      `json:"distance_m,omitempty"`
+	// Route is left out by ListVariants; use GetVariant for it.
+	Route    []Coords        `json:"route,omitempty"`
+	Schedule VariantSchedule `json:"schedule"`
+}
+
+// VariantSchedule is when a variant is run. Days are "mon" to "sun", and
+// StartTime is HH:MM in the course's timezone.
+type VariantSchedule struct {
+	Days      []string `json:"days"`
+	StartTime string   `json:"start_time,omitempty"`
+	Notes     string   `json:"notes,omitempty"`
+}
+
+type VariantInput struct {
+	Name      string          `json:"name"`
+	DistanceM *int            `json:"distance_m,omitempty"`
+	Route     []Coords        `json:"route,omitempty"`
+	Schedule  VariantSchedule `json:"schedule"`
+}
+
+// VariantPatch holds the fields to change on a variant. Nil fields are left
+// as they are, the schedule is replaced as a whole, and a pointer to an empty
+// route removes it.
+type VariantPatch struct {
+	Name      *string          `json:"name,omitempty"`
+	DistanceM *int             `json:"distance_m,omitempty"`
+	Route     *[]Coords        `json:"route,omitempty"`
+	Schedule  *VariantSchedule `json:"schedule,omitempty"`
+}
+
+// ListVariants returns a course's variants, shortest first.
+func (c *Client) ListVariants(ctx context.Context, courseID int64) ([]Variant, error) {
+	var out struct {
+		Variants []Variant `json:"variants"`
+	}
+
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/courses/%d/variants", courseID), nil, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Variants, nil
+}
+
+func (c *Client) GetVariant(ctx context.Context, courseID, id int64) (*Variant, error) {
+	var out struct {
+		Variant Variant `json:"variant"`
+	}
+
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/courses/%d/variants/%d", courseID, id), nil, nil, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Variant, nil
+}
+
+func (c *Client) CreateVariant(ctx context.Context, courseID int64, input VariantInput) (*Variant, error) {
+	var out struct {
+		Variant Variant `json:"variant"`
+	}
+
+	if input.Schedule.Days == nil {
+		input.Schedule.Days = []string{}
+	}
+
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/v1/courses/%d/variants", courseID), nil, nil, input, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Variant, nil
+}
+
+// UpdateVariant applies patch to a variant. Concurrent edits return an error
+// matching ErrEditConflict.
+func (c *Client) UpdateVariant(ctx context.Context, courseID, id int64, patch VariantPatch) (*Variant, error) {
+	var out struct {
+		Variant Variant `json:"variant"`
+	}
+
+	err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/v1/courses/%d/variants/%d", courseID, id), nil, nil, patch, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out.Variant, nil
+}
+
+func (c *Client) DeleteVariant(ctx context.Context, courseID, id int64) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/v1/courses/%d/variants/%d", courseID, id), nil, nil, nil, nil)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"peterweightman.com/runda/client"
+	"peterweightman.com/runda/internal/blob"
+	"peterweightman.com/runda/internal/database"
+	"peterweightman.com/runda/internal/metrics"
+)
+
+// newTestClient starts the API on an httptest server, with the same
+// middleware and routes as serveHTTP, and returns a client signed in as a
+// new moderator. It needs a Postgres database it can migrate, named by
+// TEST_DB_DSN, and skips the test without one.
+func newTestClient(t *testing.T) *client.Client {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DB_DSN is not set")
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	db, err := database.New(dsn, true, database.DbPoolConfig{
+		MaxOpenConns: 5,
+		MaxIdleConns: 5,
+		MaxIdleTime:  time.Minute,
+		MaxLifetime:  time.Hour,
+	}, logger)
+	if err != nil {
+		t.Fatalf("connecting to the database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{
+		logger:  logger,
+		models:  database.NewModels(db),
+		blobs:   blobs,
+		metrics: metrics.NewRegistry(db.DB.DB, version),
+	}
+	app.config.duplicates = database.DuplicateCriteria{MaxDistance: 500, MinSimilarity: 0.4}
+
+	e, err := app.newEcho()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+	app.config.baseURL = srv.URL
+
+	user := &database.User{
+		Name:  "Test moderator",
+		Email: fmt.Sprintf("moderator-%d@example.com", time.Now().UnixNano()),
+	}
+	if err = user.Password.Set("pa55word1234"); err != nil {
+		t.Fatal(err)
+	}
+	if err = app.models.Users.Insert(user); err != nil {
+		t.Fatalf("inserting user: %v", err)
+	}
+	if err = app.models.Permissions.AddForUser(user.ID, database.PermissionCoursesModerate); err != nil {
+		t.Fatalf("granting permission: %v", err)
+	}
+
+	token, err := app.models.Tokens.New(user.ID, time.Hour, database.ScopeAuthentication)
+	if err != nil {
+		t.Fatalf("creating token: %v", err)
+	}
+
+	return client.New(srv.URL, client.WithToken(token.Plaintext), client.WithRetries(0, 0))
+}
+
+// TestClientCourses runs the client's course methods against the real
+// handlers, so the two can't disagree about paths, bodies or errors.
+func TestClientCourses(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	name := fmt.Sprintf("Client test course %d", time.Now().UnixNano())
+
+	created, err := c.CreateCourse(ctx, client.CourseInput{
+		Name:     name,
+		Location: client.Coords{Latitude: 55.9533, Longitude: -3.1883},
+		Tags:     []string{"parkrun", "flat"},
+		Force:    true,
+	})
+	if err != nil {
+		t.Fatalf("CreateCourse: %v", err)
+	}
+	if created.Status != client.StatusPublished {
+		t.Errorf("got status %q, want %q", created.Status, client.StatusPublished)
+	}
+
+	got, err := c.GetCourse(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetCourse: %v", err)
+	}
+	if got.Name != name || got.Version != created.Version {
+		t.Errorf("GetCourse returned %q at version %d, want %q at version %d", got.Name, got.Version, name, created.Version)
+	}
+
+	courses, _, err := c.ListCourses(ctx, client.ListCoursesOptions{Name: name})
+	if err != nil {
+		t.Fatalf("ListCourses: %v", err)
+	}
+	found := false
+	for _, course := range courses {
+		found = found || course.ID == created.ID
+	}
+	if !found {
+		t.Errorf("ListCourses didn't return course %d", created.ID)
+	}
+
+	// An empty slice clears the tags, where leaving Tags out keeps them.
+	noTags := []string{}
+	updated, err := c.UpdateCourse(ctx, created.ID, created.Version, client.CoursePatch{Tags: &noTags})
+	if err != nil {
+		t.Fatalf("UpdateCourse: %v", err)
+	}
+	if len(updated.Tags) != 0 {
+		t.Errorf("got tags %v after clearing them", updated.Tags)
+	}
+	if updated.Version <= created.Version {
+		t.Errorf("version went from %d to %d", created.Version, updated.Version)
+	}
+
+	description := "Two laps of the park"
+	_, err = c.UpdateCourse(ctx, created.ID, created.Version, client.CoursePatch{Description: &description})
+	if !errors.Is(err, client.ErrEditConflict) {
+		t.Errorf("UpdateCourse at a stale version returned %v, want ErrEditConflict", err)
+	}
+
+	archived, err := c.ArchiveCourse(ctx, created.ID, updated.Version)
+	if err != nil {
+		t.Fatalf("ArchiveCourse: %v", err)
+	}
+
+	// Archiving twice is a conflict, but not one that refetching would fix.
+	_, err = c.ArchiveCourse(ctx, created.ID, archived.Version)
+	if !errors.Is(err, client.ErrConflict) || errors.Is(err, client.ErrEditConflict) {
+		t.Errorf("ArchiveCourse on an archived course returned %v, want ErrConflict but not ErrEditConflict", err)
+	}
+
+	if err = c.DeleteCourse(ctx, created.ID); err != nil {
+		t.Fatalf("DeleteCourse: %v", err)
+	}
+
+	_, err = c.GetCourse(ctx, created.ID)
+	if !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("GetCourse after DeleteCourse returned %v, want ErrNotFound", err)
+	}
+}
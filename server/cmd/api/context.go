@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/database"
+)
+
+const (
+	userContextKey      = "user"
+	requestIDContextKey = "request_id"
+	loggerContextKey    = "logger"
+)
+
+func (app *application) contextSetUser(c echo.Context, user *database.User) {
+	c.Set(userContextKey, user)
+}
+
+func (app *application) contextGetUser(c echo.Context) *database.User {
+	user, ok := c.Get(userContextKey).(*database.User)
+	if !ok {
+		panic("missing user value in request context")
+	}
+
+	return user
+}
+
+func (app *application) contextSetRequestID(c echo.Context, requestID string) {
+	c.Set(requestIDContextKey, requestID)
+	c.Set(loggerContextKey, app.logger.With(slog.String("request-id", requestID)))
+}
+
+func (app *application) contextGetRequestID(c echo.Context) string {
+	requestID, _ := c.Get(requestIDContextKey).(string)
+	return requestID
+}
+
+// requestLogger returns the logger for the current request, which tags every
+// line with the request ID. It falls back to the application logger for
+// anything that runs before the request ID middleware.
+func (app *application) requestLogger(c echo.Context) *slog.Logger {
+	logger, ok := c.Get(loggerContextKey).(*slog.Logger)
+	if !ok {
+		return app.logger
+	}
+
+	return logger
+}
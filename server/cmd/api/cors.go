@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// corsAllowHeaders are the request headers browsers may send cross-origin.
+var corsAllowHeaders = []string{
+	echo.HeaderAuthorization,
+	echo.HeaderContentType,
+	echo.HeaderXRequestID,
+	"X-Expected-Version",
+}
+
+// corsExposeHeaders are the response headers cross-origin scripts may read,
+// on top of the CORS-safelisted ones.
+var corsExposeHeaders = []string{
+	echo.HeaderLocation,
+	echo.HeaderXRequestID,
+	"ETag",
+}
+
+// enableCORS lets browsers on the trusted origins call the API. Preflight
+// requests are answered here, before authentication, and the allowed methods
+// are the ones the router has registered for the path, so they always match
+// addRoutes.
+func (app *application) enableCORS() (echo.MiddlewareFunc, error) {
+	patterns := make([]originPattern, 0, len(app.config.cors.trustedOrigins))
+
+	for _, origin := range app.config.cors.trustedOrigins {
+		pattern, err := parseOriginPattern(origin)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOriginFunc: func(origin string) (bool, error) {
+			for _, pattern := range patterns {
+				if pattern.matches(origin) {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+		AllowHeaders:  corsAllowHeaders,
+		ExposeHeaders: corsExposeHeaders,
+		MaxAge:        600,
+	}), nil
+}
+
+// originPattern is a trusted origin such as "https://app.example.com". The
+// leftmost label of the host may be "*" to trust every subdomain at that
+// level, as in "https://*.preview.example.com".
+type originPattern struct {
+	scheme string
+	// host is the host and port after the wildcard label (including the
+	// leading dot), or the whole host and port if there's no wildcard.
+	host     string
+	wildcard bool
+}
+
+func parseOriginPattern(s string) (originPattern, error) {
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+		return originPattern{}, fmt.Errorf("invalid trusted origin %q: must be scheme://host[:port]", s)
+	}
+
+	pattern := originPattern{scheme: strings.ToLower(u.Scheme), host: strings.ToLower(u.Host)}
+
+	if rest, ok := strings.CutPrefix(pattern.host, "*."); ok {
+		pattern.wildcard = true
+		pattern.host = "." + rest
+	}
+
+	if strings.Contains(pattern.host, "*") {
+		return originPattern{}, fmt.Errorf("invalid trusted origin %q: only the leftmost label may be a wildcard", s)
+	}
+
+	return pattern, nil
+}
+
+func (p originPattern) matches(origin string) bool {
+	scheme, host, ok := strings.Cut(strings.ToLower(origin), "://")
+	if !ok || scheme != p.scheme {
+		return false
+	}
+
+	if !p.wildcard {
+		return host == p.host
+	}
+
+	// The wildcard stands for exactly one label, so "*.example.com" trusts
+	// "a.example.com" but neither "example.com" nor "a.b.example.com".
+	label, ok := strings.CutSuffix(host, p.host)
+	return ok && label != "" && !strings.ContainsAny(label, ".:/@")
+}
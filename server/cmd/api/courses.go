@@ -4,12 +4,22 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/labstack/echo/v4"
 	"peterweightman.com/runda/internal/database"
 	"peterweightman.com/runda/internal/validation"
 )
 
+// courseSortSafelist are the sorts course lists accept. Courses without a
+// distance or elevation gain sort last either way.
+var courseSortSafelist = []string{
+	"id", "name", "rating", "distance", "elevation_gain",
+	"-id", "-name", "-rating", "-distance", "-elevation_gain",
+}
+
 func (app *application) createCourse(c echo.Context) error {
 	course := new(database.Course)
 	err := c.Bind(course)
@@ -21,12 +31,75 @@ func (app *application) createCourse(c echo.Context) error {
 		return err
 	}
 
-	err = app.models.Courses.Insert(course)
+	// The submitter follows the course through moderation in
+	// /v1/submissions.
+	course.SubmittedBy = &app.contextGetUser(c).ID
+
+	// Only an organisation's members can add courses to it.
+	if course.OrganisationID != nil {
+		if err = app.requireOrganisationRole(c, *course.OrganisationID, database.RoleOwner, database.RoleEditor); err != nil {
+			return err
+		}
+	}
+
+	// A timezone sent with the course overrides the one that would be looked
+	// up from its location.
+	course.TimezoneSource = database.TimezoneFromLocation
+	if course.Timezone != "" && course.Timezone != database.TimezoneAuto {
+		course.TimezoneSource = database.TimezoneManual
+	}
+
+	// Moderators' courses go live straight away unless they ask for a draft.
+	// Everyone else's wait for a moderator to review them.
+	moderator, err := app.userHasPermission(c, database.PermissionCoursesModerate)
 	if err != nil {
-		app.logger.Error("Error inserting course", "error", err)
+		app.requestLogger(c).Error("Error getting permissions", "error", err)
 		return echo.ErrInternalServerError
 	}
 
+	switch {
+	case !moderator:
+		course.Status = database.StatusPendingReview
+	case course.Status == "":
+		course.Status = database.StatusPublished
+	case course.Status == database.StatusRejected:
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "new courses can't be rejected")
+	}
+
+	// Volunteers often add a course that's already listed under a slightly
+	// different name. Unless they've confirmed it's new, show them the
+	// likely matches instead of creating it.
+	if c.QueryParam("force") != "true" {
+		duplicates, err := app.models.Courses.FindDuplicates(course, app.config.duplicates)
+		if err != nil {
+			app.requestLogger(c).Error("Error finding duplicate courses", "error", err)
+			return echo.ErrInternalServerError
+		}
+
+		if len(duplicates) > 0 {
+			return echo.NewHTTPError(http.StatusConflict, envelope{
+				"message":    "this course looks like a duplicate of an existing one, resend with force=true to create it anyway",
+				"duplicates": duplicates,
+			})
+		}
+	}
+
+	err = app.models.Courses.Insert(course)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrDuplicateExternalID):
+			return echo.NewHTTPError(http.StatusConflict, "a course with this external_id already exists")
+		default:
+			app.requestLogger(c).Error("Error inserting course", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	if course.Status != database.StatusPublished {
+		c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("/v1/submissions/%d", course.ID))
+		return c.JSON(http.StatusAccepted, envelope{"course": course})
+	}
+
 	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("/v1/courses/%d", course.ID))
 	return c.JSON(http.StatusCreated, envelope{"course": course})
 }
@@ -37,17 +110,29 @@ func (app *application) getCourse(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusNotFound, "course not found")
 	}
 
-	course, err := app.models.Courses.Get(id)
+	locales, err := app.readLocales(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "lang must be a language tag, such as fr or pt-BR")
+	}
+
+	course, err := app.models.Courses.GetLocalized(id, locales)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
 			return echo.ErrNotFound
 		default:
-			app.logger.Error("Error getting course", "error", err)
+			app.requestLogger(c).Error("Error getting course", "error", err)
 			return echo.ErrInternalServerError
 		}
 	}
 
+	// Merged courses live on in the course they were merged into, so send
+	// old links there.
+	if course.MergedInto != nil {
+		return c.Redirect(http.StatusMovedPermanently, fmt.Sprintf("/v1/courses/%d", *course.MergedInto))
+	}
+
+	c.Response().Header().Set("Content-Language", course.Locale)
 	return c.JSON(http.StatusOK, envelope{"course": course})
 }
 
@@ -57,7 +142,19 @@ func (app *application) updateCourse(c echo.Context) error {
 		return echo.ErrNotFound
 	}
 
-	course, err := app.models.Courses.Get(id)
+	// Moderators can also tidy up courses before they're published.
+	moderator, err := app.userHasPermission(c, database.PermissionCoursesModerate)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting permissions", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	get := app.models.Courses.Get
+	if moderator {
+		get = app.models.Courses.GetForModeration
+	}
+
+	course, err := get(id)
 	if err != nil {
 		switch {
 		case errors.Is(err, database.ErrRecordNotFound):
@@ -67,8 +164,26 @@ func (app *application) updateCourse(c echo.Context) error {
 		}
 	}
 
-	input := new(database.Course)
-	err = c.Bind(input)
+	if err = app.requireCourseEditor(c, course); err != nil {
+		return err
+	}
+
+	// Clients can make sure they're editing the version they last saw by
+	// sending it in the X-Expected-Version header.
+	if expected := c.Request().Header.Get("X-Expected-Version"); expected != "" {
+		if strconv.FormatInt(int64(course.Version), 10) != expected {
+			return echo.NewHTTPError(http.StatusConflict, "unable to update the record due to an edit conflict, please try again")
+		}
+	}
+
+	var input struct {
+		database.Course
+		// IsCertified is a pointer here so that leaving it out doesn't
+		// clear it.
+		IsCertified *bool `json:"is_certified"`
+	}
+
+	err = c.Bind(&input)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
@@ -93,6 +208,49 @@ func (app *application) updateCourse(c echo.Context) error {
 		course.Website = input.Website
 	}
 
+	if input.DistanceM != nil {
+		course.DistanceM = input.DistanceM
+	}
+
+	if input.Surface != "" {
+		course.Surface = input.Surface
+	}
+
+	if input.ElevationGainM != nil {
+		course.ElevationGainM = input.ElevationGainM
+	}
+
+	if input.Laps != nil {
+		course.Laps = input.Laps
+	}
+
+	if input.TerrainDifficulty != nil {
+		course.TerrainDifficulty = input.TerrainDifficulty
+	}
+
+	if input.IsCertified != nil {
+		course.IsCertified = *input.IsCertified
+	}
+
+	course.Facilities.Apply(input.Facilities)
+
+	// Moving a course to another organisation needs membership of both.
+	if input.OrganisationID != nil && (course.OrganisationID == nil || *input.OrganisationID != *course.OrganisationID) {
+		if err = app.requireOrganisationRole(c, *input.OrganisationID, database.RoleOwner, database.RoleEditor); err != nil {
+			return err
+		}
+		course.OrganisationID = input.OrganisationID
+	}
+
+	switch input.Timezone {
+	case "":
+	case database.TimezoneAuto:
+		course.TimezoneSource = database.TimezoneFromLocation
+	default:
+		course.Timezone = input.Timezone
+		course.TimezoneSource = database.TimezoneManual
+	}
+
 	if err = c.Validate(course); err != nil {
 		return err
 	}
@@ -103,7 +261,84 @@ func (app *application) updateCourse(c echo.Context) error {
 		case errors.Is(err, database.ErrEditConflict):
 			return echo.NewHTTPError(http.StatusConflict, "unable to update the record due to an edit conflict, please try again")
 		default:
-			app.logger.Error("Error updating course", "error", err)
+			app.requestLogger(c).Error("Error updating course", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.JSON(http.StatusOK, envelope{"course": course})
+}
+
+// listDuplicateCourses lets moderators review pairs of existing courses that
+// look like the same one, using the same rules as createCourse.
+func (app *application) listDuplicateCourses(c echo.Context) error {
+	var filters database.Filters
+	var err error
+
+	filters.Page, err = app.readInt(c, "page", 1)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid page number")
+	}
+	filters.PageSize, err = app.readInt(c, "page_size", 20)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid page size")
+	}
+
+	// Pairs are always most similar first.
+	filters.Sort = "-similarity"
+	filters.SortSafelist = []string{"-similarity"}
+
+	if err = validation.ValidateFilters(filters); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	pairs, metadata, err := app.models.Courses.GetDuplicatePairs(app.config.duplicates, filters)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting duplicate courses", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, envelope{"duplicates": pairs, "metadata": metadata})
+}
+
+func (app *application) archiveCourse(c echo.Context) error {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	course, err := app.models.Courses.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting course", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	if err = app.requireCourseEditor(c, course); err != nil {
+		return err
+	}
+
+	if expected := c.Request().Header.Get("X-Expected-Version"); expected != "" {
+		if strconv.FormatInt(int64(course.Version), 10) != expected {
+			return echo.NewHTTPError(http.StatusConflict, "unable to update the record due to an edit conflict, please try again")
+		}
+	}
+
+	if course.ArchivedAt != nil {
+		return echo.NewHTTPError(http.StatusConflict, "course is already archived")
+	}
+
+	err = app.models.Courses.Archive(course)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			return echo.NewHTTPError(http.StatusConflict, "unable to update the record due to an edit conflict, please try again")
+		default:
+			app.requestLogger(c).Error("Error archiving course", "error", err)
 			return echo.ErrInternalServerError
 		}
 	}
@@ -117,6 +352,29 @@ func (app *application) deleteCourse(c echo.Context) error {
 		return echo.ErrNotFound
 	}
 
+	course, err := app.models.Courses.GetForModeration(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting course", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	if err = app.requireCourseEditor(c, course); err != nil {
+		return err
+	}
+
+	// The photo rows are removed along with the course, so grab them first to
+	// know which files to clean up afterwards.
+	photos, err := app.models.Photos.GetAllForCourse(id)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting photos", "error", err)
+		return echo.ErrInternalServerError
+	}
+
 	err = app.models.Courses.Delete(id)
 	if err != nil {
 		switch {
@@ -127,13 +385,16 @@ func (app *application) deleteCourse(c echo.Context) error {
 		}
 	}
 
+	for _, photo := range photos {
+		app.deletePhotoBlobs(c, photo)
+	}
+
 	return c.NoContent(http.StatusOK)
 }
 
 func (app *application) listCourses(c echo.Context) error {
 	var input struct {
-		Name string
-		Tags []string
+		database.CourseSearch
 		database.Filters
 	}
 
@@ -141,6 +402,72 @@ func (app *application) listCourses(c echo.Context) error {
 	input.Tags = app.readCSV(c, "tags", []string{})
 
 	var err error
+	input.MinRating, err = app.readFloat(c, "min_rating", 0)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid minimum rating")
+	}
+
+	input.CountryCode = strings.ToUpper(c.QueryParam("country"))
+	if input.CountryCode != "" && !isCountryCode(input.CountryCode) {
+		return echo.NewHTTPError(http.StatusBadRequest, "country must be a two-letter ISO 3166-1 code")
+	}
+
+	input.Region = c.QueryParam("region")
+
+	ranges := []struct {
+		param string
+		dest  **int
+	}{
+		{"distance_min", &input.DistanceMin},
+		{"distance_max", &input.DistanceMax},
+		{"elevation_gain_min", &input.ElevationGainMin},
+		{"elevation_gain_max", &input.ElevationGainMax},
+		{"terrain_difficulty_min", &input.TerrainDifficultyMin},
+		{"terrain_difficulty_max", &input.TerrainDifficultyMax},
+		{"variant_distance_min", &input.VariantDistanceMin},
+		{"variant_distance_max", &input.VariantDistanceMax},
+	}
+
+	for _, r := range ranges {
+		*r.dest, err = app.readOptionalInt(c, r.param)
+		if err != nil || *r.dest != nil && **r.dest < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("%s must be a whole number of at least 0", r.param))
+		}
+	}
+
+	input.Surfaces = app.readCSV(c, "surface", []string{})
+	for _, surface := range input.Surfaces {
+		if !slices.Contains(database.Surfaces, surface) {
+			return echo.NewHTTPError(http.StatusBadRequest, "surface must be one or more of road, trail, grass, track or mixed")
+		}
+	}
+
+	input.Certified, err = app.readOptionalBool(c, "certified")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "certified must be true or false")
+	}
+
+	input.Locales, err = app.readLocales(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "lang must be a language tag, such as fr or pt-BR")
+	}
+
+	input.Facilities = app.readCSV(c, "facilities", []string{})
+	for _, facility := range input.Facilities {
+		if !slices.Contains(database.FacilityNames, facility) {
+			return echo.NewHTTPError(http.StatusBadRequest, "facilities must be one or more of "+strings.Join(database.FacilityNames, ", "))
+		}
+	}
+
+	organisationID, err := app.readOptionalInt(c, "organisation")
+	if err != nil || organisationID != nil && *organisationID < 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, "organisation must be an organisation ID")
+	}
+	if organisationID != nil {
+		id := int64(*organisationID)
+		input.OrganisationID = &id
+	}
+
 	input.Filters.Page, err = app.readInt(c, "page", 1)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid page number")
@@ -154,18 +481,53 @@ func (app *application) listCourses(c echo.Context) error {
 	if input.Filters.Sort == "" {
 		input.Filters.Sort = "id"
 	}
-
-	input.Filters.SortSafelist = []string{"id", "name", "-id", "-name"}
+	input.Filters.SortSafelist = courseSortSafelist
 
 	if err = validation.ValidateFilters(input.Filters); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	courses, metadata, err := app.models.Courses.GetAll(input.Name, input.Tags, input.Filters)
+	courses, metadata, err := app.models.Courses.GetAll(input.CourseSearch, input.Filters)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting courses", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	facets, err := app.models.Courses.GetFacets(input.CourseSearch)
 	if err != nil {
-		app.logger.Error("Error getting courses", "error", err)
+		app.requestLogger(c).Error("Error getting course facets", "error", err)
 		return echo.ErrInternalServerError
 	}
 
-	return c.JSON(http.StatusOK, envelope{"courses": courses, "metadata": metadata})
+	return c.JSON(http.StatusOK, envelope{"courses": courses, "facets": facets, "metadata": metadata})
+}
+
+// requireCourseEditor returns an error to send unless the user making the
+// request can change the course directly. Courses run by an organisation can
+// be changed by its members, and all others by moderators. Everyone else
+// suggests edits for a moderator to review instead.
+func (app *application) requireCourseEditor(c echo.Context, course *database.Course) error {
+	if course.OrganisationID != nil {
+		return app.requireCourseMember(c, course)
+	}
+
+	if app.contextGetUser(c).IsAnonymous() {
+		return echo.NewHTTPError(http.StatusUnauthorized, "you must be authenticated to access this resource")
+	}
+
+	moderator, err := app.userHasPermission(c, database.PermissionCoursesModerate)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting permissions", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	if !moderator {
+		return echo.NewHTTPError(http.StatusForbidden, "only moderators can change this course, suggest an edit instead")
+	}
+
+	return nil
+}
+
+func isCountryCode(s string) bool {
+	return len(s) == 2 && s[0] >= 'A' && s[0] <= 'Z' && s[1] >= 'A' && s[1] <= 'Z'
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// errorHandler writes every error response in the same shape as echo's
+// default handler, with the request ID added so that a response can be
+// matched up with the log lines for the request.
+func (app *application) errorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	var he *echo.HTTPError
+	if !errors.As(err, &he) {
+		he = echo.ErrInternalServerError
+	}
+
+	if internal, ok := he.Internal.(*echo.HTTPError); ok {
+		he = internal
+	}
+
+	body := envelope{}
+
+	switch m := he.Message.(type) {
+	case envelope:
+		for k, v := range m {
+			body[k] = v
+		}
+	case string:
+		body["message"] = m
+	case error:
+		body["message"] = m.Error()
+	default:
+		body["message"] = m
+	}
+
+	body["request_id"] = app.contextGetRequestID(c)
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(he.Code)
+	} else {
+		err = c.JSON(he.Code, body)
+	}
+	if err != nil {
+		app.requestLogger(c).Error("Error writing error response", "error", err)
+	}
+}
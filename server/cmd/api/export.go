@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/courseio"
+	"peterweightman.com/runda/internal/database"
+)
+
+func (app *application) exportCourses(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = courseio.FormatNDJSON
+	}
+
+	includeArchived := c.QueryParam("include_archived") == "true"
+
+	res := c.Response()
+
+	w, err := courseio.NewWriter(res, format)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "format must be one of csv, ndjson or geojson")
+	}
+
+	// An export of the whole table will easily outlast the server's write
+	// timeout, so lift it for this response only.
+	err = http.NewResponseController(res).SetWriteDeadline(time.Time{})
+	if err != nil {
+		app.requestLogger(c).Error("Error clearing write deadline", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	filename := fmt.Sprintf("courses-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format)
+
+	res.Header().Set(echo.HeaderContentType, courseio.ContentType(format))
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	res.WriteHeader(http.StatusOK)
+
+	count := 0
+	err = app.models.Courses.Export(c.Request().Context(), includeArchived, func(course *database.Course) error {
+		err := w.Write(course)
+		if err != nil {
+			return err
+		}
+
+		count++
+		if count%1000 == 0 {
+			res.Flush()
+		}
+
+		return nil
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		// The status line has already gone out, so the only way left to tell
+		// the client the file is incomplete is to drop the connection.
+		app.requestLogger(c).Error("Error exporting courses", "error", err, "exported", count)
+		panic(http.ErrAbortHandler)
+	}
+
+	return nil
+}
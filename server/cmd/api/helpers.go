@@ -43,3 +43,50 @@ func (app *application) readInt(c echo.Context, key string, defaultValue int) (i
 
 	return i, nil
 }
+
+// readOptionalInt is readInt for parameters with no default, returning nil
+// when the parameter isn't set.
+func (app *application) readOptionalInt(c echo.Context, key string) (*int, error) {
+	s := c.QueryParam(key)
+
+	if s == "" {
+		return nil, nil
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+func (app *application) readOptionalBool(c echo.Context, key string) (*bool, error) {
+	s := c.QueryParam(key)
+
+	if s == "" {
+		return nil, nil
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+func (app *application) readFloat(c echo.Context, key string, defaultValue float64) (float64, error) {
+	s := c.QueryParam(key)
+
+	if s == "" {
+		return defaultValue, nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return defaultValue, err
+	}
+
+	return f, nil
+}
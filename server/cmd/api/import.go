@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/courseio"
+	"peterweightman.com/runda/internal/database"
+)
+
+const (
+	maxImportBytes = 10 * 1024 * 1024
+	maxImportRows  = 10_000
+)
+
+func (app *application) importCourses(c echo.Context) error {
+	format, err := app.readImportFormat(c)
+	if err != nil {
+		return err
+	}
+
+	dryRun := c.QueryParam("dry_run") == "true"
+	upsert := c.QueryParam("upsert") == "true"
+
+	body := http.MaxBytesReader(c.Response(), c.Request().Body, maxImportBytes)
+
+	records, err := courseio.Read(body, format)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "import file must not be larger than 10MB")
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	if len(records) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "import file contains no courses")
+	}
+
+	if len(records) > maxImportRows {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "import file must not contain more than 10000 courses")
+	}
+
+	courses, rowErrors := courseio.Check(records)
+
+	if dryRun {
+		return c.JSON(http.StatusOK, envelope{
+			"dry_run": true,
+			"total":   len(records),
+			"valid":   len(courses),
+			"errors":  rowErrors,
+		})
+	}
+
+	if len(rowErrors) > 0 {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, envelope{
+			"message": "no courses were imported as some rows are invalid",
+			"errors":  rowErrors,
+		})
+	}
+
+	result, err := app.models.Courses.Import(courses, upsert)
+	if err != nil {
+		var rowErr *database.ImportRowError
+		switch {
+		case errors.As(err, &rowErr) && errors.Is(err, database.ErrDuplicateExternalID):
+			return echo.NewHTTPError(http.StatusConflict, envelope{
+				"message": "no courses were imported as an external_id already exists, use upsert=true to update existing courses",
+				"errors": []courseio.RowError{{
+					Row:        records[rowErr.Index].Row,
+					ExternalID: courses[rowErr.Index].ExternalID,
+					Error:      "a course with this external_id already exists",
+				}},
+			})
+		case errors.As(err, &rowErr) && errors.Is(err, database.ErrMergedCourse):
+			return echo.NewHTTPError(http.StatusConflict, envelope{
+				"message": "no courses were imported as an external_id belongs to a course that has been merged into another",
+				"errors": []courseio.RowError{{
+					Row:        records[rowErr.Index].Row,
+					ExternalID: courses[rowErr.Index].ExternalID,
+					Error:      "the course with this external_id has been merged into another",
+				}},
+			})
+		default:
+			app.requestLogger(c).Error("Error importing courses", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.JSON(http.StatusOK, envelope{"import": result})
+}
+
+// readImportFormat picks the import format from the format query parameter,
+// falling back to the request's Content-Type.
+func (app *application) readImportFormat(c echo.Context) (string, error) {
+	switch c.QueryParam("format") {
+	case courseio.FormatCSV:
+		return courseio.FormatCSV, nil
+	case courseio.FormatGeoJSON:
+		return courseio.FormatGeoJSON, nil
+	case "":
+	default:
+		return "", echo.NewHTTPError(http.StatusBadRequest, "format must be one of csv or geojson")
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+
+	switch mediaType {
+	case "text/csv":
+		return courseio.FormatCSV, nil
+	case "application/geo+json", echo.MIMEApplicationJSON:
+		return courseio.FormatGeoJSON, nil
+	default:
+		return "", echo.NewHTTPError(http.StatusUnsupportedMediaType, "import must be sent as text/csv or application/geo+json")
+	}
+}
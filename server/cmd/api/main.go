@@ -1,26 +1,33 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"runtime/debug"
+	"strings"
 	"time"
 
+	"peterweightman.com/runda/internal/blob"
 	"peterweightman.com/runda/internal/database"
 	"peterweightman.com/runda/internal/env"
+	"peterweightman.com/runda/internal/metrics"
+	"peterweightman.com/runda/internal/stream"
 
 	"github.com/lmittmann/tint"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const version = "0.0.1"
 
 type config struct {
-	httpPort int
-	env      string
-	baseURL  string
-	db       struct {
+	httpPort    int
+	metricsPort int
+	env         string
+	baseURL     string
+	db          struct {
 		dsn          string
 		automigrate  bool
 		maxOpenConns int
@@ -28,12 +35,38 @@ type config struct {
 		maxIdleTime  time.Duration
 		maxLifetime  time.Duration
 	}
+	storage struct {
+		dir string
+	}
+	photos struct {
+		maxSize int64
+	}
+	openapi struct {
+		validate bool
+	}
+	cors struct {
+		trustedOrigins []string
+	}
+	tls struct {
+		certFile     string
+		keyFile      string
+		redirectPort int
+	}
+	webhooks struct {
+		enabled      bool
+		pollInterval time.Duration
+		retention    time.Duration
+	}
+	duplicates database.DuplicateCriteria
 }
 
 type application struct {
-	config config
-	logger *slog.Logger
-	models database.Models
+	config  config
+	logger  *slog.Logger
+	models  database.Models
+	blobs   blob.Store
+	metrics *prometheus.Registry
+	stream  *stream.Broker
 }
 
 func main() {
@@ -52,6 +85,7 @@ func run(logger *slog.Logger) error {
 
 	flag.StringVar(&cfg.baseURL, "base-url", "http://localhost:4000", "Base URL")
 	flag.IntVar(&cfg.httpPort, "port", 4000, "API server port")
+	flag.IntVar(&cfg.metricsPort, "metrics-port", env.GetInt("METRICS_PORT", 4001), "Admin server port for /metrics, 0 to disable [env var: METRICS_PORT]")
 	flag.StringVar(&cfg.env, "env", env.GetString("ENV", "development"), "Environment (development|staging|production) [env var: ENV]")
 
 	flag.StringVar(&cfg.db.dsn, "db-dsn", env.GetString("DB_DSN", ""), "PostgreSQL DSN [env var: DB_DSN]")
@@ -61,6 +95,28 @@ func run(logger *slog.Logger) error {
 	flag.DurationVar(&cfg.db.maxIdleTime, "db-max-idle-time", env.GetDuration("DB_MAX_IDLE_TIME", time.Minute, 15), "PostgreSQL max connection idle time (mins) [env var: DB_MAX_IDLE_TIME]")
 	flag.DurationVar(&cfg.db.maxLifetime, "db-max-lifetime", env.GetDuration("DB_MAX_LIFETIME", time.Hour, 2), "PostgreSQL max connection lifetime (hours) [env var: DB_MAX_IDLE_TIME]")
 
+	flag.StringVar(&cfg.storage.dir, "storage-dir", env.GetString("STORAGE_DIR", "./uploads"), "Directory for uploaded files [env var: STORAGE_DIR]")
+	flag.Int64Var(&cfg.photos.maxSize, "photo-max-size", int64(env.GetInt("PHOTO_MAX_SIZE", 10*1024*1024)), "Maximum photo upload size (bytes) [env var: PHOTO_MAX_SIZE]")
+
+	flag.BoolVar(&cfg.openapi.validate, "openapi-validate", env.GetBool("OPENAPI_VALIDATE", false), "Reject requests that don't match the OpenAPI document [env var: OPENAPI_VALIDATE]")
+
+	flag.StringVar(&cfg.tls.certFile, "tls-cert", env.GetString("TLS_CERT", ""), "TLS certificate file, reloaded when it changes or on SIGHUP [env var: TLS_CERT]")
+	flag.StringVar(&cfg.tls.keyFile, "tls-key", env.GetString("TLS_KEY", ""), "TLS private key file [env var: TLS_KEY]")
+	flag.IntVar(&cfg.tls.redirectPort, "tls-redirect-port", env.GetInt("TLS_REDIRECT_PORT", 0), "Port redirecting plain HTTP to HTTPS, 0 to disable [env var: TLS_REDIRECT_PORT]")
+
+	flag.BoolVar(&cfg.webhooks.enabled, "webhooks-enabled", env.GetBool("WEBHOOKS_ENABLED", true), "Send webhook deliveries from this instance [env var: WEBHOOKS_ENABLED]")
+	flag.DurationVar(&cfg.webhooks.pollInterval, "webhooks-poll-interval", env.GetDuration("WEBHOOKS_POLL_INTERVAL", time.Second, 2), "How often to check for webhook events (secs) [env var: WEBHOOKS_POLL_INTERVAL]")
+	flag.DurationVar(&cfg.webhooks.retention, "webhooks-retention", env.GetDuration("WEBHOOKS_RETENTION", 24*time.Hour, 30), "How long to keep sent events and their deliveries, 0 to keep them forever (days) [env var: WEBHOOKS_RETENTION]")
+
+	flag.Float64Var(&cfg.duplicates.MaxDistance, "duplicates-max-distance", env.GetFloat("DUPLICATES_MAX_DISTANCE", 500), "How close courses must be to count as possible duplicates (metres) [env var: DUPLICATES_MAX_DISTANCE]")
+	flag.Float64Var(&cfg.duplicates.MinSimilarity, "duplicates-min-similarity", env.GetFloat("DUPLICATES_MIN_SIMILARITY", 0.4), "How similar course names must be to count as possible duplicates (0-1) [env var: DUPLICATES_MIN_SIMILARITY]")
+
+	cfg.cors.trustedOrigins = strings.Fields(env.GetString("CORS_TRUSTED_ORIGINS", ""))
+	flag.Func("cors-trusted-origins", "Trusted CORS origins, space separated, e.g. https://*.preview.example.com [env var: CORS_TRUSTED_ORIGINS]", func(val string) error {
+		cfg.cors.trustedOrigins = strings.Fields(val)
+		return nil
+	})
+
 	showVersion := flag.Bool("version", false, "display version and exit")
 
 	flag.Parse()
@@ -70,6 +126,10 @@ func run(logger *slog.Logger) error {
 		return nil
 	}
 
+	if (cfg.tls.certFile == "") != (cfg.tls.keyFile == "") {
+		return errors.New("-tls-cert and -tls-key must be set together")
+	}
+
 	if cfg.db.dsn == "" {
 		panic("DB_DSN environment variable is not set")
 	}
@@ -87,10 +147,17 @@ func run(logger *slog.Logger) error {
 	defer db.Close()
 	logger.Info("database connection pool established")
 
+	blobs, err := blob.NewLocalStore(cfg.storage.dir)
+	if err != nil {
+		return err
+	}
+
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: database.NewModels(db),
+		config:  cfg,
+		logger:  logger,
+		models:  database.NewModels(db),
+		blobs:   blobs,
+		metrics: metrics.NewRegistry(db.DB.DB, version),
 	}
 
 	return app.serveHTTP()
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/database"
+)
+
+// mergeCourse folds the course in source_id into the course in the URL, for
+// when both describe the same place. The source is archived and its URL
+// redirects to the survivor from then on.
+func (app *application) mergeCourse(c echo.Context) error {
+	targetID, err := app.readIDParam(c)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	var input struct {
+		SourceID int64             `json:"source_id" validate:"required,min=1"`
+		Strategy string            `json:"strategy" validate:"omitempty,oneof=target source newest"`
+		Fields   map[string]string `json:"fields" validate:"omitempty,dive,keys,oneof=name description location website external_id distance_m surface elevation_gain_m laps terrain_difficulty facilities,endkeys,oneof=target source newest"`
+	}
+
+	err = c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = c.Validate(&input); err != nil {
+		return err
+	}
+
+	if input.SourceID == targetID {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "a course can't be merged into itself")
+	}
+
+	target, err := app.models.Courses.Get(targetID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting course", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	if expected := c.Request().Header.Get("X-Expected-Version"); expected != "" {
+		if strconv.FormatInt(int64(target.Version), 10) != expected {
+			return echo.NewHTTPError(http.StatusConflict, "unable to update the record due to an edit conflict, please try again")
+		}
+	}
+
+	source, err := app.models.Courses.Get(input.SourceID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "source course not found")
+		default:
+			app.requestLogger(c).Error("Error getting course", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	if target.ArchivedAt != nil {
+		return echo.NewHTTPError(http.StatusConflict, "courses can't be merged into an archived course")
+	}
+
+	if source.ArchivedAt != nil {
+		return echo.NewHTTPError(http.StatusConflict, "the source course is archived or has already been merged")
+	}
+
+	// Merging changes the target and archives the source, so neither can
+	// belong to an organisation the moderator isn't a member of.
+	for _, course := range []*database.Course{target, source} {
+		if err = app.requireCourseEditor(c, course); err != nil {
+			return err
+		}
+	}
+
+	strategy := database.MergeStrategy{Default: input.Strategy, Fields: input.Fields}
+	if strategy.Default == "" {
+		strategy.Default = database.MergeTarget
+	}
+
+	strategy.Apply(target, source)
+
+	if err = c.Validate(target); err != nil {
+		return err
+	}
+
+	err = app.models.Courses.Merge(target, source)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			return echo.NewHTTPError(http.StatusConflict, "unable to update the record due to an edit conflict, please try again")
+		case errors.Is(err, database.ErrDuplicateExternalID):
+			return echo.NewHTTPError(http.StatusConflict, "a course with this external_id already exists")
+		default:
+			app.requestLogger(c).Error("Error merging courses", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.JSON(http.StatusOK, envelope{"course": target})
+}
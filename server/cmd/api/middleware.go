@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/database"
+	"peterweightman.com/runda/internal/metrics"
+)
+
+// requestID takes the request ID from the X-Request-ID header, or generates
+// one if the client didn't send a usable one, and echoes it back in the
+// response. The request header is overwritten with the ID that's used, so the
+// access log always records the same ID as the handler logs.
+func (app *application) requestID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+
+		requestID := req.Header.Get(echo.HeaderXRequestID)
+		if !validRequestID(requestID) {
+			b := make([]byte, 16)
+			_, err := rand.Read(b)
+			if err != nil {
+				return err
+			}
+			requestID = hex.EncodeToString(b)
+		}
+
+		req.Header.Set(echo.HeaderXRequestID, requestID)
+		c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+		app.contextSetRequestID(c, requestID)
+
+		return next(c)
+	}
+}
+
+// validRequestID limits the request IDs we accept from clients, as they're
+// copied straight into our logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func (app *application) authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		c.Response().Header().Add(echo.HeaderVary, echo.HeaderAuthorization)
+
+		authorizationHeader := c.Request().Header.Get(echo.HeaderAuthorization)
+		if authorizationHeader == "" {
+			app.contextSetUser(c, database.AnonymousUser)
+			return next(c)
+		}
+
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			return app.invalidAuthenticationToken(c)
+		}
+
+		user, err := app.models.Users.GetForToken(database.ScopeAuthentication, headerParts[1])
+		if err != nil {
+			switch {
+			case errors.Is(err, database.ErrRecordNotFound):
+				return app.invalidAuthenticationToken(c)
+			default:
+				app.requestLogger(c).Error("Error getting user for token", "error", err)
+				return echo.ErrInternalServerError
+			}
+		}
+
+		app.contextSetUser(c, user)
+		return next(c)
+	}
+}
+
+func (app *application) requireAuthenticatedUser(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user := app.contextGetUser(c)
+
+		if user.IsAnonymous() {
+			return echo.NewHTTPError(http.StatusUnauthorized, "you must be authenticated to access this resource")
+		}
+
+		return next(c)
+	}
+}
+
+func (app *application) requirePermission(code string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return app.requireAuthenticatedUser(func(c echo.Context) error {
+			allowed, err := app.userHasPermission(c, code)
+			if err != nil {
+				app.requestLogger(c).Error("Error getting permissions", "error", err)
+				return echo.ErrInternalServerError
+			}
+
+			if !allowed {
+				return echo.NewHTTPError(http.StatusForbidden, "your user account doesn't have the necessary permissions to access this resource")
+			}
+
+			return next(c)
+		})
+	}
+}
+
+// userHasPermission reports whether the user making the request has the
+// permission, for handlers that behave differently for privileged users.
+func (app *application) userHasPermission(c echo.Context, code string) (bool, error) {
+	user := app.contextGetUser(c)
+	if user.IsAnonymous() {
+		return false, nil
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return permissions.Include(code), nil
+}
+
+func (app *application) invalidAuthenticationToken(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+	return echo.NewHTTPError(http.StatusUnauthorized, "invalid or missing authentication token")
+}
+
+func (app *application) recordMetrics(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		err := next(c)
+
+		// Errors are only written out by the error handler once the middleware
+		// chain has returned, so the status has to be worked out from the error.
+		status := c.Response().Status
+		if err != nil {
+			var httpErr *echo.HTTPError
+			if errors.As(err, &httpErr) {
+				status = httpErr.Code
+			} else {
+				status = http.StatusInternalServerError
+			}
+		}
+
+		// Use the route pattern rather than the request path, so that every
+		// course doesn't get its own series.
+		route := c.Path()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		labels := []string{c.Request().Method, route, strconv.Itoa(status)}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/database"
+	"peterweightman.com/runda/internal/validation"
+)
+
+// listModerationQueue shows moderators the courses waiting for review, oldest
+// first. The status parameter also lets them look through drafts and past
+// rejections.
+func (app *application) listModerationQueue(c echo.Context) error {
+	var filters database.Filters
+	var err error
+
+	status := c.QueryParam("status")
+	if status == "" {
+		status = database.StatusPendingReview
+	}
+
+	if !slices.Contains([]string{database.StatusDraft, database.StatusPendingReview, database.StatusRejected}, status) {
+		return echo.NewHTTPError(http.StatusBadRequest, "status must be one of draft, pending_review or rejected")
+	}
+
+	filters.Page, err = app.readInt(c, "page", 1)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid page number")
+	}
+	filters.PageSize, err = app.readInt(c, "page_size", 20)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid page size")
+	}
+
+	filters.Sort = c.QueryParam("sort")
+	if filters.Sort == "" {
+		filters.Sort = "created_at"
+	}
+
+	filters.SortSafelist = []string{"created_at", "-created_at"}
+
+	if err = validation.ValidateFilters(filters); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	courses, metadata, err := app.models.Courses.GetModerationQueue(status, filters)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting moderation queue", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, envelope{"courses": courses, "metadata": metadata})
+}
+
+func (app *application) approveCourse(c echo.Context) error {
+	var input struct {
+		Reason string `json:"reason" validate:"max=1000"`
+	}
+
+	// The body is optional when approving, which Bind allows.
+	err := c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = c.Validate(&input); err != nil {
+		return err
+	}
+
+	return app.moderateCourse(c, database.DecisionApprove, input.Reason)
+}
+
+func (app *application) rejectCourse(c echo.Context) error {
+	var input struct {
+		Reason string `json:"reason" validate:"required,max=1000"`
+	}
+
+	err := c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = c.Validate(&input); err != nil {
+		return err
+	}
+
+	return app.moderateCourse(c, database.DecisionReject, input.Reason)
+}
+
+// moderateCourse applies a moderator's decision to the course in the URL.
+// Anything not yet published can be approved, including a course that was
+// rejected by mistake, but only drafts and pending courses can be rejected.
+func (app *application) moderateCourse(c echo.Context, decision, reason string) error {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	course, err := app.models.Courses.GetForModeration(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting course", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	if expected := c.Request().Header.Get("X-Expected-Version"); expected != "" {
+		if strconv.FormatInt(int64(course.Version), 10) != expected {
+			return echo.NewHTTPError(http.StatusConflict, "unable to update the record due to an edit conflict, please try again")
+		}
+	}
+
+	switch {
+	case course.Status == database.StatusPublished:
+		return echo.NewHTTPError(http.StatusConflict, "course is already published")
+	case decision == database.DecisionReject && course.Status == database.StatusRejected:
+		return echo.NewHTTPError(http.StatusConflict, "course is already rejected")
+	}
+
+	moderator := app.contextGetUser(c)
+
+	record := &database.ModerationDecision{
+		ModeratorID: &moderator.ID,
+		Decision:    decision,
+		Reason:      reason,
+	}
+
+	err = app.models.Courses.Moderate(course, record)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			return echo.NewHTTPError(http.StatusConflict, "unable to update the record due to an edit conflict, please try again")
+		default:
+			app.requestLogger(c).Error("Error moderating course", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.JSON(http.StatusOK, envelope{"course": course, "decision": record})
+}
+
+// listModerationDecisions is the audit trail of every approval and rejection,
+// newest first, optionally for a single course.
+func (app *application) listModerationDecisions(c echo.Context) error {
+	var filters database.Filters
+
+	courseID, err := app.readInt(c, "course_id", 0)
+	if err != nil || courseID < 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid course ID")
+	}
+
+	filters.Page, err = app.readInt(c, "page", 1)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid page number")
+	}
+	filters.PageSize, err = app.readInt(c, "page_size", 20)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid page size")
+	}
+
+	filters.Sort = "-id"
+	filters.SortSafelist = []string{"-id"}
+
+	if err = validation.ValidateFilters(filters); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	decisions, metadata, err := app.models.Courses.GetModerationDecisions(int64(courseID), filters)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting moderation decisions", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, envelope{"decisions": decisions, "metadata": metadata})
+}
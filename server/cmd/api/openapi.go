@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/assets"
+	"peterweightman.com/runda/internal/openapi"
+)
+
+func (app *application) openAPISpec(c echo.Context) error {
+	data, err := assets.EmbeddedFiles.ReadFile("openapi/openapi.json")
+	if err != nil {
+		app.requestLogger(c).Error("Error reading OpenAPI document", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, data)
+}
+
+func (app *application) apiDocs(c echo.Context) error {
+	data, err := assets.EmbeddedFiles.ReadFile("openapi/docs.html")
+	if err != nil {
+		app.requestLogger(c).Error("Error reading API docs page", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.HTMLBlob(http.StatusOK, data)
+}
+
+// apiDocsAsset serves the Swagger UI files behind the docs page, which are
+// vendored from swagger-ui-dist so the page works without reaching a CDN.
+func (app *application) apiDocsAsset(c echo.Context) error {
+	files, err := fs.Sub(assets.EmbeddedFiles, "openapi/swagger-ui")
+	if err != nil {
+		app.requestLogger(c).Error("Error reading Swagger UI files", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return echo.StaticDirectoryHandler(files, false)(c)
+}
+
+func loadOpenAPIDocument() (*openapi.Document, error) {
+	data, err := assets.EmbeddedFiles.ReadFile("openapi/openapi.json")
+	if err != nil {
+		return nil, err
+	}
+
+	return openapi.Load(data)
+}
+
+// openAPIPath converts an echo route path such as "/v1/courses/:id" into the
+// templated form used by OpenAPI, "/v1/courses/{id}". A trailing wildcard
+// becomes a {path} parameter.
+func openAPIPath(echoPath string) string {
+	segments := strings.Split(echoPath, "/")
+
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, ":"):
+			segments[i] = "{" + segment[1:] + "}"
+		case segment == "*":
+			segments[i] = "{path}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// maxValidatedBodyBytes limits the JSON bodies validateRequest reads into
+// memory. Routes that accept larger bodies have their own limit here.
+var maxValidatedBodyBytes = map[string]int64{
+	"/v1/courses/import": maxImportBytes,
+}
+
+// validateRequest rejects requests that don't match the OpenAPI document,
+// before they reach the handlers.
+func (app *application) validateRequest(doc *openapi.Document) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			op := doc.Operation(c.Request().Method, openAPIPath(c.Path()))
+			if op == nil {
+				return next(c)
+			}
+
+			pathParams := make(map[string]string)
+			for i, name := range c.ParamNames() {
+				if name == "*" {
+					name = "path"
+				}
+				pathParams[name] = c.ParamValues()[i]
+			}
+
+			var body []byte
+
+			// Only JSON bodies are validated, so leave uploads and imports to
+			// stream through to their handlers.
+			if op.RequestBody != nil && strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+				var err error
+
+				maxBytes, ok := maxValidatedBodyBytes[c.Path()]
+				if !ok {
+					maxBytes = 1024 * 1024
+				}
+
+				body, err = io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, maxBytes))
+				if err != nil {
+					return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("request body must not be larger than %dMB", maxBytes/(1024*1024)))
+				}
+
+				c.Request().Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			problems := doc.ValidateRequest(op, c.Request(), pathParams, body)
+			if problems != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, envelope{
+					"message": "request does not match the API specification",
+					"errors":  problems,
+				})
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/openapi"
+)
+
+// TestOpenAPIDocumentCoversRoutes makes sure that every route in addRoutes is
+// described in the OpenAPI document and vice versa, so the two can't drift
+// apart.
+func TestOpenAPIDocumentCoversRoutes(t *testing.T) {
+	doc, err := loadOpenAPIDocument()
+	if err != nil {
+		t.Fatalf("loading OpenAPI document: %v", err)
+	}
+
+	app := &application{}
+	e := echo.New()
+	app.addRoutes(e)
+
+	registered := make(map[openapi.Route]bool)
+	for _, r := range e.Routes() {
+		registered[openapi.Route{Method: r.Method, Path: openAPIPath(r.Path)}] = true
+	}
+
+	documented := make(map[openapi.Route]bool)
+	for _, r := range doc.Routes() {
+		documented[r] = true
+	}
+
+	for r := range registered {
+		if !documented[r] {
+			t.Errorf("%s %s is missing from the OpenAPI document", r.Method, r.Path)
+		}
+	}
+
+	for r := range documented {
+		if !registered[r] {
+			t.Errorf("%s %s is in the OpenAPI document but has no route", r.Method, r.Path)
+		}
+	}
+}
+
+// TestValidateRequestImportLimit checks that GeoJSON imports sent as plain
+// JSON get the import's own size limit rather than the usual 1MB.
+func TestValidateRequestImportLimit(t *testing.T) {
+	doc, err := loadOpenAPIDocument()
+	if err != nil {
+		t.Fatalf("loading OpenAPI document: %v", err)
+	}
+
+	app := &application{}
+	e := echo.New()
+	e.Use(app.validateRequest(doc))
+	e.POST("/v1/courses/import", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name string
+		size int
+		want int
+	}{
+		{"over 1MB", 2 * 1024 * 1024, http.StatusNoContent},
+		{"over the import limit", maxImportBytes + 1, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"type":"FeatureCollection","features":[],"padding":"` + strings.Repeat("x", tt.size) + `"}`
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/courses/import", strings.NewReader(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("got status %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+// TestAPIDocsAssets checks that the docs page's scripts and styles are
+// served from the binary.
+func TestAPIDocsAssets(t *testing.T) {
+	app := &application{}
+	e := echo.New()
+	app.addRoutes(e)
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/v1/docs/swagger-ui-bundle.js", http.StatusOK},
+		{"/v1/docs/swagger-ui.css", http.StatusOK},
+		{"/v1/docs/missing.js", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if rec.Code != tt.want {
+			t.Errorf("GET %s: got status %d, want %d", tt.path, rec.Code, tt.want)
+		}
+	}
+}
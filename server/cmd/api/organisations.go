@@ -0,0 +1,333 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/database"
+	"peterweightman.com/runda/internal/validation"
+)
+
+func (app *application) createOrganisation(c echo.Context) error {
+	var organisation database.Organisation
+
+	err := c.Bind(&organisation)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = c.Validate(&organisation); err != nil {
+		return err
+	}
+
+	// Whoever creates the organisation owns it, so there's always someone to
+	// add the other members.
+	err = app.models.Organisations.Insert(&organisation, app.contextGetUser(c).ID)
+	if err != nil {
+		app.requestLogger(c).Error("Error inserting organisation", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("/v1/organisations/%d", organisation.ID))
+	return c.JSON(http.StatusCreated, envelope{"organisation": organisation})
+}
+
+func (app *application) getOrganisation(c echo.Context) error {
+	organisation, err := app.readOrganisation(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, envelope{"organisation": organisation})
+}
+
+func (app *application) updateOrganisation(c echo.Context) error {
+	organisation, err := app.readOrganisation(c)
+	if err != nil {
+		return err
+	}
+
+	if err = app.requireOrganisationRole(c, organisation.ID, database.RoleOwner); err != nil {
+		return err
+	}
+
+	var input struct {
+		Name    *string `json:"name"`
+		Website *string `json:"website"`
+	}
+
+	err = c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if input.Name != nil {
+		organisation.Name = *input.Name
+	}
+
+	if input.Website != nil {
+		organisation.Website = *input.Website
+	}
+
+	if err = c.Validate(organisation); err != nil {
+		return err
+	}
+
+	err = app.models.Organisations.Update(organisation)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			return echo.NewHTTPError(http.StatusConflict, "unable to update the record due to an edit conflict, please try again")
+		default:
+			app.requestLogger(c).Error("Error updating organisation", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.JSON(http.StatusOK, envelope{"organisation": organisation})
+}
+
+func (app *application) deleteOrganisation(c echo.Context) error {
+	organisation, err := app.readOrganisation(c)
+	if err != nil {
+		return err
+	}
+
+	if err = app.requireOrganisationRole(c, organisation.ID, database.RoleOwner); err != nil {
+		return err
+	}
+
+	err = app.models.Organisations.Delete(organisation.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		case errors.Is(err, database.ErrOrganisationInUse):
+			return echo.NewHTTPError(http.StatusConflict, "the organisation still runs courses, move them to another organisation or delete them first")
+		default:
+			app.requestLogger(c).Error("Error deleting organisation", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (app *application) listOrganisationMembers(c echo.Context) error {
+	organisation, err := app.readOrganisation(c)
+	if err != nil {
+		return err
+	}
+
+	if err = app.requireOrganisationRole(c, organisation.ID, database.RoleOwner, database.RoleEditor); err != nil {
+		return err
+	}
+
+	members, err := app.models.Organisations.GetMembers(organisation.ID)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting organisation members", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, envelope{"members": members})
+}
+
+// putOrganisationMember adds a user to the organisation or changes their
+// role.
+func (app *application) putOrganisationMember(c echo.Context) error {
+	organisation, err := app.readOrganisation(c)
+	if err != nil {
+		return err
+	}
+
+	userID, err := readUserIDParam(c)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	if err = app.requireOrganisationRole(c, organisation.ID, database.RoleOwner); err != nil {
+		return err
+	}
+
+	var input struct {
+		Role string `json:"role" validate:"required,oneof=owner editor"`
+	}
+
+	err = c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = c.Validate(&input); err != nil {
+		return err
+	}
+
+	member, err := app.models.Organisations.SetMember(organisation.ID, userID, input.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "user not found")
+		case errors.Is(err, database.ErrLastOwner):
+			return echo.NewHTTPError(http.StatusConflict, "an organisation must keep at least one owner")
+		default:
+			app.requestLogger(c).Error("Error setting organisation member", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.JSON(http.StatusOK, envelope{"member": member})
+}
+
+// deleteOrganisationMember takes a user out of the organisation. Owners can
+// remove anyone, and other members can leave.
+func (app *application) deleteOrganisationMember(c echo.Context) error {
+	organisation, err := app.readOrganisation(c)
+	if err != nil {
+		return err
+	}
+
+	userID, err := readUserIDParam(c)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	if userID != app.contextGetUser(c).ID {
+		if err = app.requireOrganisationRole(c, organisation.ID, database.RoleOwner); err != nil {
+			return err
+		}
+	}
+
+	err = app.models.Organisations.RemoveMember(organisation.ID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		case errors.Is(err, database.ErrLastOwner):
+			return echo.NewHTTPError(http.StatusConflict, "an organisation must keep at least one owner")
+		default:
+			app.requestLogger(c).Error("Error removing organisation member", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// listOrganisationCourses lists the published courses the organisation runs,
+// paged and sorted like listCourses.
+func (app *application) listOrganisationCourses(c echo.Context) error {
+	organisation, err := app.readOrganisation(c)
+	if err != nil {
+		return err
+	}
+
+	search := database.CourseSearch{OrganisationID: &organisation.ID}
+
+	search.Locales, err = app.readLocales(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "lang must be a language tag, such as fr or pt-BR")
+	}
+
+	var filters database.Filters
+
+	filters.Page, err = app.readInt(c, "page", 1)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid page number")
+	}
+	filters.PageSize, err = app.readInt(c, "page_size", 20)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid page size")
+	}
+
+	filters.Sort = c.QueryParam("sort")
+	if filters.Sort == "" {
+		filters.Sort = "id"
+	}
+	filters.SortSafelist = courseSortSafelist
+
+	if err = validation.ValidateFilters(filters); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	courses, metadata, err := app.models.Courses.GetAll(search, filters)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting courses", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, envelope{"courses": courses, "metadata": metadata})
+}
+
+// readOrganisation looks up the organisation in the :id parameter.
+func (app *application) readOrganisation(c echo.Context) (*database.Organisation, error) {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		return nil, echo.ErrNotFound
+	}
+
+	organisation, err := app.models.Organisations.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return nil, echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting organisation", "error", err)
+			return nil, echo.ErrInternalServerError
+		}
+	}
+
+	return organisation, nil
+}
+
+func readUserIDParam(c echo.Context) (int64, error) {
+	id, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid user_id parameter")
+	}
+
+	return id, nil
+}
+
+// requireOrganisationRole returns an error to send unless the user making
+// the request has one of the roles in the organisation.
+func (app *application) requireOrganisationRole(c echo.Context, organisationID int64, roles ...string) error {
+	user := app.contextGetUser(c)
+	if user.IsAnonymous() {
+		return echo.NewHTTPError(http.StatusUnauthorized, "you must be authenticated to access this resource")
+	}
+
+	role, err := app.models.Organisations.GetRole(organisationID, user.ID)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting organisation role", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	if !slices.Contains(roles, role) {
+		return echo.NewHTTPError(http.StatusForbidden, "your role in the organisation doesn't allow this")
+	}
+
+	return nil
+}
+
+// requireCourseMember returns an error to send unless the user making the
+// request is a member of the organisation that runs the course. It's only
+// called through requireCourseEditor, which decides who can change courses
+// without an organisation.
+func (app *application) requireCourseMember(c echo.Context, course *database.Course) error {
+	if course.OrganisationID == nil {
+		return nil
+	}
+
+	err := app.requireOrganisationRole(c, *course.OrganisationID, database.RoleOwner, database.RoleEditor)
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code == http.StatusForbidden {
+		return echo.NewHTTPError(http.StatusForbidden, "only members of the course's organisation can change it")
+	}
+
+	return err
+}
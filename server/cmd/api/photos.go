@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/blob"
+	"peterweightman.com/runda/internal/database"
+	"peterweightman.com/runda/internal/thumbnail"
+)
+
+// photoContentTypes maps the image types we accept for upload onto the
+// extension used to store the original.
+var photoContentTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+func (app *application) uploadPhoto(c echo.Context) error {
+	course, err := app.readPhotoCourse(c)
+	if err != nil {
+		return err
+	}
+
+	if err = app.requireCourseEditor(c, course); err != nil {
+		return err
+	}
+
+	// Allow a little headroom over the file size limit for the rest of the
+	// multipart form.
+	maxBytes := app.config.photos.maxSize
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxBytes+64*1024)
+
+	fileHeader, err := c.FormFile("photo")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("photo must not be larger than %d bytes", maxBytes))
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, "a photo must be uploaded in the \"photo\" form field")
+		}
+	}
+
+	if fileHeader.Size > maxBytes {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("photo must not be larger than %d bytes", maxBytes))
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		app.requestLogger(c).Error("Error opening uploaded photo", "error", err)
+		return echo.ErrInternalServerError
+	}
+	defer file.Close()
+
+	mtype, err := mimetype.DetectReader(file)
+	if err != nil {
+		app.requestLogger(c).Error("Error detecting photo content type", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	extension, ok := photoContentTypes[mtype.String()]
+	if !ok {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "photo must be a JPEG, PNG or GIF image")
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		app.requestLogger(c).Error("Error rewinding uploaded photo", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	img, err := thumbnail.Decode(file)
+	if err != nil {
+		switch {
+		case errors.Is(err, thumbnail.ErrTooLarge):
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("photo must not have more than %d pixels", thumbnail.MaxPixels))
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, "unable to decode photo")
+		}
+	}
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		app.requestLogger(c).Error("Error rewinding uploaded photo", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	randomBytes := make([]byte, 16)
+	_, err = rand.Read(randomBytes)
+	if err != nil {
+		app.requestLogger(c).Error("Error generating photo key", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	photo := &database.Photo{
+		CourseID:    course.ID,
+		Caption:     c.FormValue("caption"),
+		ContentType: mtype.String(),
+		SizeBytes:   fileHeader.Size,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		KeyPrefix:   fmt.Sprintf("courses/%d/photos/%s", course.ID, hex.EncodeToString(randomBytes)),
+		Extension:   extension,
+	}
+
+	ctx := c.Request().Context()
+
+	err = app.blobs.Put(ctx, photo.OriginalKey(), file)
+	if err != nil {
+		app.requestLogger(c).Error("Error storing photo", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	for _, size := range thumbnail.Sizes {
+		var buf bytes.Buffer
+
+		err = thumbnail.EncodeJPEG(&buf, thumbnail.Fit(img, size.MaxSide))
+		if err == nil {
+			err = app.blobs.Put(ctx, photo.ThumbnailKey(size.Name), &buf)
+		}
+		if err != nil {
+			app.requestLogger(c).Error("Error storing photo thumbnail", "size", size.Name, "error", err)
+			app.deletePhotoBlobs(c, photo)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	err = app.models.Photos.Insert(photo)
+	if err != nil {
+		app.requestLogger(c).Error("Error inserting photo", "error", err)
+		app.deletePhotoBlobs(c, photo)
+		return echo.ErrInternalServerError
+	}
+
+	app.setPhotoURLs(photo)
+
+	return c.JSON(http.StatusCreated, envelope{"photo": photo})
+}
+
+func (app *application) listPhotos(c echo.Context) error {
+	course, err := app.readPhotoCourse(c)
+	if err != nil {
+		return err
+	}
+
+	photos, err := app.models.Photos.GetAllForCourse(course.ID)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting photos", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	for _, photo := range photos {
+		app.setPhotoURLs(photo)
+	}
+
+	return c.JSON(http.StatusOK, envelope{"photos": photos})
+}
+
+func (app *application) updatePhoto(c echo.Context) error {
+	course, err := app.readPhotoCourse(c)
+	if err != nil {
+		return err
+	}
+
+	if err = app.requireCourseEditor(c, course); err != nil {
+		return err
+	}
+
+	photo, err := app.readCoursePhoto(c)
+	if err != nil {
+		return err
+	}
+
+	var input struct {
+		Caption *string `json:"caption" validate:"omitempty,max=1000"`
+	}
+
+	err = c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = c.Validate(&input); err != nil {
+		return err
+	}
+
+	if input.Caption != nil {
+		photo.Caption = *input.Caption
+	}
+
+	err = app.models.Photos.Update(photo)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			return echo.NewHTTPError(http.StatusConflict, "unable to update the record due to an edit conflict, please try again")
+		default:
+			app.requestLogger(c).Error("Error updating photo", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	app.setPhotoURLs(photo)
+
+	return c.JSON(http.StatusOK, envelope{"photo": photo})
+}
+
+func (app *application) reorderPhotos(c echo.Context) error {
+	course, err := app.readPhotoCourse(c)
+	if err != nil {
+		return err
+	}
+
+	if err = app.requireCourseEditor(c, course); err != nil {
+		return err
+	}
+
+	var input struct {
+		PhotoIDs []int64 `json:"photo_ids" validate:"required"`
+	}
+
+	err = c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = c.Validate(&input); err != nil {
+		return err
+	}
+
+	err = app.models.Photos.Reorder(course.ID, input.PhotoIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrPhotoSetMismatch):
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "photo_ids must list every photo of the course exactly once")
+		default:
+			app.requestLogger(c).Error("Error reordering photos", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return app.listPhotos(c)
+}
+
+func (app *application) deletePhoto(c echo.Context) error {
+	course, err := app.readPhotoCourse(c)
+	if err != nil {
+		return err
+	}
+
+	if err = app.requireCourseEditor(c, course); err != nil {
+		return err
+	}
+
+	photo, err := app.readCoursePhoto(c)
+	if err != nil {
+		return err
+	}
+
+	err = app.models.Photos.Delete(photo.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error deleting photo", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	app.deletePhotoBlobs(c, photo)
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (app *application) serveMedia(c echo.Context) error {
+	key := c.Param("*")
+
+	r, err := app.blobs.Get(c.Request().Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, blob.ErrNotFound):
+			return echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting media", "key", key, "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+	defer r.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = echo.MIMEOctetStream
+	}
+
+	// Keys are never reused, so the files behind them never change.
+	c.Response().Header().Set(echo.HeaderCacheControl, "public, max-age=31536000, immutable")
+	return c.Stream(http.StatusOK, contentType, r)
+}
+
+// readPhotoCourse looks up the published course in the :id parameter.
+func (app *application) readPhotoCourse(c echo.Context) (*database.Course, error) {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		return nil, echo.ErrNotFound
+	}
+
+	course, err := app.models.Courses.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return nil, echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting course", "error", err)
+			return nil, echo.ErrInternalServerError
+		}
+	}
+
+	return course, nil
+}
+
+// readCoursePhoto looks up the photo from the :photo_id parameter, making sure
+// it belongs to the course in the :id parameter.
+func (app *application) readCoursePhoto(c echo.Context) (*database.Photo, error) {
+	courseID, err := app.readIDParam(c)
+	if err != nil {
+		return nil, echo.ErrNotFound
+	}
+
+	photoID, err := strconv.ParseInt(c.Param("photo_id"), 10, 64)
+	if err != nil || photoID < 1 {
+		return nil, echo.ErrNotFound
+	}
+
+	photo, err := app.models.Photos.Get(photoID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return nil, echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting photo", "error", err)
+			return nil, echo.ErrInternalServerError
+		}
+	}
+
+	if photo.CourseID != courseID {
+		return nil, echo.ErrNotFound
+	}
+
+	return photo, nil
+}
+
+func (app *application) setPhotoURLs(photo *database.Photo) {
+	photo.URL = app.mediaURL(photo.OriginalKey())
+	photo.Thumbnails = make(map[string]string, len(thumbnail.Sizes))
+
+	for _, size := range thumbnail.Sizes {
+		photo.Thumbnails[size.Name] = app.mediaURL(photo.ThumbnailKey(size.Name))
+	}
+}
+
+func (app *application) mediaURL(key string) string {
+	return app.config.baseURL + "/media/" + key
+}
+
+func (app *application) deletePhotoBlobs(c echo.Context, photo *database.Photo) {
+	keys := []string{photo.OriginalKey()}
+	for _, size := range thumbnail.Sizes {
+		keys = append(keys, photo.ThumbnailKey(size.Name))
+	}
+
+	for _, key := range keys {
+		// Use a fresh context, as the files should still be cleaned up if the
+		// client has already gone away.
+		err := app.blobs.Delete(context.Background(), key)
+		if err != nil {
+			app.requestLogger(c).Error("Error deleting photo blob", "key", key, "error", err)
+		}
+	}
+}
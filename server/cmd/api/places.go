@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// backfillPlaces looks up the country, region and timezone of any courses
+// written before those were recorded. Once that's been done it has nothing to
+// do, so it's cheap to run on every start.
+func (app *application) backfillPlaces(ctx context.Context) {
+	n, err := app.models.Courses.BackfillPlaces(ctx, false)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		app.logger.Error("backfilling course places failed", "error", err, "updated", n)
+		return
+	}
+
+	if n > 0 {
+		app.logger.Info("backfilled course places", "updated", n)
+	}
+}
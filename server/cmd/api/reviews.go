@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/database"
+	"peterweightman.com/runda/internal/validation"
+)
+
+func (app *application) createReview(c echo.Context) error {
+	courseID, err := app.readIDParam(c)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	_, err = app.models.Courses.Get(courseID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting course", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	var input struct {
+		Rating int    `json:"rating"`
+		Text   string `json:"text"`
+	}
+
+	err = c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	review := &database.Review{
+		CourseID: courseID,
+		UserID:   app.contextGetUser(c).ID,
+		Rating:   input.Rating,
+		Text:     input.Text,
+	}
+
+	if err = validation.ValidateReview(review); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = app.models.Reviews.Insert(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrDuplicateReview):
+			return echo.NewHTTPError(http.StatusConflict, "you have already reviewed this course, edit your existing review instead")
+		default:
+			app.requestLogger(c).Error("Error inserting review", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("/v1/courses/%d/reviews/%d", courseID, review.ID))
+	return c.JSON(http.StatusCreated, envelope{"review": review})
+}
+
+func (app *application) updateReview(c echo.Context) error {
+	courseID, err := app.readIDParam(c)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	reviewID, err := strconv.ParseInt(c.Param("review_id"), 10, 64)
+	if err != nil || reviewID < 1 {
+		return echo.ErrNotFound
+	}
+
+	review, err := app.models.Reviews.Get(reviewID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting review", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	if review.CourseID != courseID {
+		return echo.ErrNotFound
+	}
+
+	if review.UserID != app.contextGetUser(c).ID {
+		return echo.NewHTTPError(http.StatusForbidden, "you can only edit your own reviews")
+	}
+
+	var input struct {
+		Rating *int    `json:"rating"`
+		Text   *string `json:"text"`
+	}
+
+	err = c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if input.Rating != nil {
+		review.Rating = *input.Rating
+	}
+
+	if input.Text != nil {
+		review.Text = *input.Text
+	}
+
+	if err = validation.ValidateReview(review); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	err = app.models.Reviews.Update(review)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			return echo.NewHTTPError(http.StatusConflict, "unable to update the record due to an edit conflict, please try again")
+		default:
+			app.requestLogger(c).Error("Error updating review", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.JSON(http.StatusOK, envelope{"review": review})
+}
+
+func (app *application) listReviews(c echo.Context) error {
+	courseID, err := app.readIDParam(c)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	_, err = app.models.Courses.Get(courseID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting course", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	var filters database.Filters
+
+	filters.Page, err = app.readInt(c, "page", 1)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid page number")
+	}
+	filters.PageSize, err = app.readInt(c, "page_size", 20)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid page size")
+	}
+
+	filters.Sort = c.QueryParam("sort")
+	if filters.Sort == "" {
+		filters.Sort = "-created_at"
+	}
+
+	filters.SortSafelist = []string{"created_at", "rating", "-created_at", "-rating"}
+
+	if err = validation.ValidateFilters(filters); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	reviews, metadata, err := app.models.Reviews.GetAllForCourse(courseID, filters)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting reviews", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, envelope{"reviews": reviews, "metadata": metadata})
+}
@@ -2,14 +2,81 @@ package main
 
 import (
 	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/database"
 )
 
 func (app *application) addRoutes(e *echo.Echo) {
 	e.GET("/v1/status", app.healthCheck)
+	e.GET("/v1/openapi.json", app.openAPISpec)
+	e.GET("/v1/docs", app.apiDocs)
+	e.GET("/v1/docs/*", app.apiDocsAsset)
 
 	e.GET("/v1/courses", app.listCourses)
+	e.GET("/v1/courses/export", app.exportCourses)
+	e.GET("/v1/courses/changes", app.listCourseChanges)
+	e.GET("/v1/courses/stream", app.streamCourses)
+	e.GET("/v1/courses/duplicates", app.listDuplicateCourses, app.requirePermission(database.PermissionCoursesModerate))
 	e.GET("/v1/courses/:id", app.getCourse)
-	e.POST("/v1/courses", app.createCourse)
-	e.PATCH("/v1/courses/:id", app.updateCourse)
-	e.DELETE("/v1/courses/:id", app.deleteCourse)
+	e.POST("/v1/courses", app.createCourse, app.requireAuthenticatedUser)
+	e.POST("/v1/courses/import", app.importCourses, app.requirePermission(database.PermissionCoursesImport))
+	e.PATCH("/v1/courses/:id", app.updateCourse, app.requireAuthenticatedUser)
+	e.DELETE("/v1/courses/:id", app.deleteCourse, app.requireAuthenticatedUser)
+	e.POST("/v1/courses/:id/archive", app.archiveCourse, app.requireAuthenticatedUser)
+	e.POST("/v1/courses/:id/merge", app.mergeCourse, app.requirePermission(database.PermissionCoursesModerate))
+
+	e.GET("/v1/courses/:id/reviews", app.listReviews)
+	e.POST("/v1/courses/:id/reviews", app.createReview, app.requireAuthenticatedUser)
+	e.PATCH("/v1/courses/:id/reviews/:review_id", app.updateReview, app.requireAuthenticatedUser)
+
+	e.GET("/v1/courses/:id/suggestions", app.listCourseSuggestions, app.requirePermission(database.PermissionCoursesModerate))
+	e.POST("/v1/courses/:id/suggestions", app.createSuggestion, app.requireAuthenticatedUser)
+
+	e.GET("/v1/courses/:id/photos", app.listPhotos)
+	e.POST("/v1/courses/:id/photos", app.uploadPhoto, app.requireAuthenticatedUser)
+	e.PUT("/v1/courses/:id/photos/order", app.reorderPhotos, app.requireAuthenticatedUser)
+	e.PATCH("/v1/courses/:id/photos/:photo_id", app.updatePhoto, app.requireAuthenticatedUser)
+	e.DELETE("/v1/courses/:id/photos/:photo_id", app.deletePhoto, app.requireAuthenticatedUser)
+
+	e.GET("/v1/courses/:id/variants", app.listVariants)
+	e.POST("/v1/courses/:id/variants", app.createVariant, app.requireAuthenticatedUser)
+	e.GET("/v1/courses/:id/variants/:variant_id", app.getVariant)
+	e.PATCH("/v1/courses/:id/variants/:variant_id", app.updateVariant, app.requireAuthenticatedUser)
+	e.DELETE("/v1/courses/:id/variants/:variant_id", app.deleteVariant, app.requireAuthenticatedUser)
+
+	e.GET("/v1/courses/:id/translations", app.listTranslations)
+	e.PUT("/v1/courses/:id/translations/:locale", app.putTranslation, app.requireAuthenticatedUser)
+	e.DELETE("/v1/courses/:id/translations/:locale", app.deleteTranslation, app.requireAuthenticatedUser)
+
+	e.GET("/media/*", app.serveMedia)
+
+	e.POST("/v1/organisations", app.createOrganisation, app.requireAuthenticatedUser)
+	e.GET("/v1/organisations/:id", app.getOrganisation)
+	e.PATCH("/v1/organisations/:id", app.updateOrganisation, app.requireAuthenticatedUser)
+	e.DELETE("/v1/organisations/:id", app.deleteOrganisation, app.requireAuthenticatedUser)
+	e.GET("/v1/organisations/:id/courses", app.listOrganisationCourses)
+	e.GET("/v1/organisations/:id/members", app.listOrganisationMembers, app.requireAuthenticatedUser)
+	e.PUT("/v1/organisations/:id/members/:user_id", app.putOrganisationMember, app.requireAuthenticatedUser)
+	e.DELETE("/v1/organisations/:id/members/:user_id", app.deleteOrganisationMember, app.requireAuthenticatedUser)
+
+	e.GET("/v1/moderation/queue", app.listModerationQueue, app.requirePermission(database.PermissionCoursesModerate))
+	e.POST("/v1/moderation/queue/:id/approve", app.approveCourse, app.requirePermission(database.PermissionCoursesModerate))
+	e.POST("/v1/moderation/queue/:id/reject", app.rejectCourse, app.requirePermission(database.PermissionCoursesModerate))
+	e.GET("/v1/moderation/decisions", app.listModerationDecisions, app.requirePermission(database.PermissionCoursesModerate))
+
+	e.GET("/v1/submissions", app.listSubmissions, app.requireAuthenticatedUser)
+	e.GET("/v1/submissions/:id", app.getSubmission, app.requireAuthenticatedUser)
+
+	e.GET("/v1/suggestions", app.listUserSuggestions, app.requireAuthenticatedUser)
+	e.GET("/v1/suggestions/:id", app.getSuggestion, app.requireAuthenticatedUser)
+	e.POST("/v1/suggestions/:id/accept", app.acceptSuggestion, app.requirePermission(database.PermissionCoursesModerate))
+	e.POST("/v1/suggestions/:id/reject", app.rejectSuggestion, app.requirePermission(database.PermissionCoursesModerate))
+
+	e.GET("/v1/webhooks", app.listWebhooks, app.requirePermission(database.PermissionWebhooksManage))
+	e.POST("/v1/webhooks", app.createWebhook, app.requirePermission(database.PermissionWebhooksManage))
+	e.GET("/v1/webhooks/:id", app.getWebhook, app.requirePermission(database.PermissionWebhooksManage))
+	e.DELETE("/v1/webhooks/:id", app.deleteWebhook, app.requirePermission(database.PermissionWebhooksManage))
+	e.GET("/v1/webhooks/:id/deliveries", app.listWebhookDeliveries, app.requirePermission(database.PermissionWebhooksManage))
+
+	e.POST("/v1/users", app.registerUser)
+	e.POST("/v1/tokens/authentication", app.createAuthenticationToken)
 }
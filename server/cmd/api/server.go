@@ -1,20 +1,31 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"peterweightman.com/runda/internal/certs"
+	"peterweightman.com/runda/internal/stream"
 	"peterweightman.com/runda/internal/validation"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	slogecho "github.com/samber/slog-echo"
 )
 
+// shutdownTimeout is how long in-flight requests get to finish after a
+// SIGINT or SIGTERM.
+const shutdownTimeout = 30 * time.Second
+
 type CustomValidator struct {
 	validator *validator.Validate
 }
@@ -29,15 +40,56 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 	return nil
 }
 
-func (app *application) serveHTTP() error {
+// newEcho sets up the API's middleware and routes.
+func (app *application) newEcho() (*echo.Echo, error) {
 	e := echo.New()
 	e.Validator = &CustomValidator{validator: validation.NewValidator()}
+	e.HTTPErrorHandler = app.errorHandler
 
+	e.Use(app.requestID)
+	e.Use(app.recordMetrics)
 	e.Use(slogecho.New(app.logger))
-	e.Use(middleware.Recover())
+	e.Use(middleware.RecoverWithConfig(middleware.RecoverConfig{
+		LogErrorFunc: func(c echo.Context, err error, stack []byte) error {
+			app.requestLogger(c).Error("Recovered from panic", "error", err, "trace", string(stack))
+			return err
+		},
+	}))
+
+	cors, err := app.enableCORS()
+	if err != nil {
+		return nil, err
+	}
+	e.Use(cors)
+
+	e.Use(app.authenticate)
+
+	if app.config.openapi.validate {
+		doc, err := loadOpenAPIDocument()
+		if err != nil {
+			return nil, err
+		}
+
+		e.Use(app.validateRequest(doc))
+	}
 
 	app.addRoutes(e)
 
+	return e, nil
+}
+
+func (app *application) serveHTTP() error {
+	e, err := app.newEcho()
+	if err != nil {
+		return err
+	}
+
+	app.stream = &stream.Broker{
+		Events: app.models.Events,
+		DSN:    app.config.db.dsn,
+		Logger: app.logger,
+	}
+
 	s := http.Server{
 		Addr:         fmt.Sprintf(":%d", app.config.httpPort),
 		Handler:      e,
@@ -47,12 +99,100 @@ func (app *application) serveHTTP() error {
 		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
 	}
 
-	app.logger.Info("starting server", slog.Group("server", "addr", s.Addr), slog.String("env", app.config.env))
+	if app.config.metricsPort != 0 {
+		go app.serveAdmin()
+	}
 
-	err := s.ListenAndServe()
+	// Background work stops when shutdown begins. That includes the event
+	// broker, which closes every open stream so that Shutdown isn't left
+	// waiting on responses that would never finish.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.RegisterOnShutdown(cancel)
+
+	go app.stream.Run(ctx)
+
+	if app.config.webhooks.enabled {
+		go app.runWebhookDispatcher(ctx)
+	}
+
+	go app.backfillPlaces(ctx)
+
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-quit
+
+		app.logger.Info("shutting down server", "signal", sig.String())
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		shutdownError <- s.Shutdown(ctx)
+	}()
+
+	if app.config.tls.certFile == "" {
+		app.logger.Info("starting server", slog.Group("server", "addr", s.Addr), slog.String("env", app.config.env))
+
+		err = s.ListenAndServe()
+	} else {
+		var reloader *certs.Reloader
+
+		reloader, err = certs.NewReloader(app.config.tls.certFile, app.config.tls.keyFile)
+		if err != nil {
+			return err
+		}
+		s.TLSConfig = newTLSConfig(reloader)
+
+		go app.watchCertificate(reloader)
+
+		if app.config.tls.redirectPort != 0 {
+			go app.serveRedirect()
+		}
+
+		app.logger.Info("starting server", slog.Group("server", "addr", s.Addr, "tls", true), slog.String("env", app.config.env), slog.Time("certificate_expiry", reloader.Expiry()))
+
+		// The certificate comes from TLSConfig.GetCertificate, so no files
+		// are passed here.
+		err = s.ListenAndServeTLS("", "")
+	}
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 
+	err = <-shutdownError
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("stopped server", slog.Group("server", "addr", s.Addr))
+
 	return nil
 }
+
+// serveAdmin runs the admin server, which is kept on its own port so that
+// /metrics can be left off the public network.
+func (app *application) serveAdmin() {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(app.metrics, promhttp.HandlerOpts{
+		ErrorLog: slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+	}))
+
+	s := http.Server{
+		Addr:         fmt.Sprintf(":%d", app.config.metricsPort),
+		Handler:      mux,
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+	}
+
+	app.logger.Info("starting admin server", slog.Group("server", "addr", s.Addr))
+
+	err := s.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		app.logger.Error("admin server stopped", "error", err)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/database"
+	"peterweightman.com/runda/internal/stream"
+)
+
+const (
+	// streamHeartbeatInterval keeps idle streams from being closed by proxies,
+	// and notices clients that have gone away.
+	streamHeartbeatInterval = 15 * time.Second
+
+	// streamWriteTimeout replaces the server's WriteTimeout for streams. It
+	// applies to each write rather than the whole response, so a stream can
+	// stay open indefinitely but a client that stops reading is dropped.
+	streamWriteTimeout = 10 * time.Second
+
+	// streamRetry is how long browsers wait before reconnecting, in
+	// milliseconds.
+	streamRetry = 3000
+)
+
+// streamFilter limits a stream to courses with all of the tags and within the
+// bounding box. Deletions always pass, as the course they were for is gone,
+// and clients can ignore IDs they don't have. An update to a course that
+// matched before but doesn't any more is sent as a deletion, as the client
+// may still be holding the course from before it moved away or lost a tag.
+type streamFilter struct {
+	tags []string
+	bbox *[4]float64 // min longitude, min latitude, max longitude, max latitude
+}
+
+func (f streamFilter) matches(location database.Coords, tags []string) bool {
+	for _, tag := range f.tags {
+		if !slices.Contains(tags, tag) {
+			return false
+		}
+	}
+
+	if f.bbox != nil {
+		lon, lat := location.Longitude, location.Latitude
+		if lon < f.bbox[0] || lat < f.bbox[1] || lon > f.bbox[2] || lat > f.bbox[3] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// apply returns the event to send in place of event, or nil if there's
+// nothing to send.
+func (f streamFilter) apply(event *stream.Event) *stream.Event {
+	if event.Type == database.EventCourseDeleted || f.matches(event.Course.Location, event.Course.Tags) {
+		return event
+	}
+
+	// Updates that can move a course or change its tags carry what they were
+	// before. Anything else that doesn't match now didn't match before
+	// either, so the client never had the course.
+	if event.Previous == nil || !f.matches(event.Previous.Location, event.Previous.Tags) {
+		return nil
+	}
+
+	data, _ := json.Marshal(map[string]any{"course": map[string]int64{"id": event.CourseID}})
+
+	deleted := *event.Event
+	deleted.Type = database.EventCourseDeleted
+	deleted.Data = data
+
+	return &stream.Event{Event: &deleted, Course: database.Course{ID: event.CourseID}}
+}
+
+func (app *application) readBBox(c echo.Context) (*[4]float64, error) {
+	s := c.QueryParam("bbox")
+	if s == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, errors.New("bbox must be min_lon,min_lat,max_lon,max_lat")
+	}
+
+	var bbox [4]float64
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, errors.New("bbox must be min_lon,min_lat,max_lon,max_lat")
+		}
+		bbox[i] = f
+	}
+
+	if bbox[0] > bbox[2] || bbox[1] > bbox[3] {
+		return nil, errors.New("bbox minimums must not be greater than its maximums")
+	}
+
+	return &bbox, nil
+}
+
+// streamCourses sends course events as server-sent events. Clients resume
+// after a disconnect with the Last-Event-ID header, which browsers send
+// automatically, or the last_event_id query parameter.
+func (app *application) streamCourses(c echo.Context) error {
+	var filter streamFilter
+	var err error
+
+	filter.tags = app.readCSV(c, "tags", nil)
+
+	filter.bbox, err = app.readBBox(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	lastEventID := c.Request().Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.QueryParam("last_event_id")
+	}
+
+	var lastID int64
+	if lastEventID != "" {
+		lastID, err = strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || lastID < 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid last event ID")
+		}
+	}
+
+	// Subscribe before reading the backlog, so that nothing committed in
+	// between is missed. Anything in both is skipped by ID below.
+	sub := app.stream.Subscribe()
+	defer app.stream.Unsubscribe(sub)
+
+	res := c.Response()
+	rc := http.NewResponseController(res)
+
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	write := func(s string) error {
+		err := rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if err != nil {
+			return err
+		}
+
+		_, err = res.Write([]byte(s))
+		if err != nil {
+			return err
+		}
+
+		return rc.Flush()
+	}
+
+	send := func(event *stream.Event) error {
+		if event.ID <= lastID {
+			return nil
+		}
+		lastID = event.ID
+
+		event = filter.apply(event)
+		if event == nil {
+			return nil
+		}
+
+		var data bytes.Buffer
+		err := json.Compact(&data, event.Data)
+		if err != nil {
+			return err
+		}
+
+		return write(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data.Bytes()))
+	}
+
+	err = write(fmt.Sprintf("retry: %d\n\n", streamRetry))
+	if err != nil {
+		return nil
+	}
+
+	if lastEventID != "" {
+		for {
+			events, err := app.models.Events.GetAfter(lastID, 500)
+			if err != nil {
+				app.requestLogger(c).Error("Error reading events", "error", err)
+				return nil
+			}
+
+			for _, event := range events {
+				err = send(stream.Decode(event))
+				if err != nil {
+					return nil
+				}
+			}
+
+			if len(events) < 500 {
+				break
+			}
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case event, ok := <-sub.C:
+			// Closed because we fell behind or the server is shutting down.
+			// Either way the client reconnects and resumes from lastID.
+			if !ok {
+				return nil
+			}
+			err = send(event)
+		case <-heartbeat.C:
+			err = write(": heartbeat\n\n")
+		}
+
+		// Write errors just mean the client has gone.
+		if err != nil {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/database"
+	"peterweightman.com/runda/internal/validation"
+)
+
+// listSubmissions shows users the courses they've added, newest first, so
+// they can follow them through moderation.
+func (app *application) listSubmissions(c echo.Context) error {
+	var filters database.Filters
+	var err error
+
+	status := c.QueryParam("status")
+	if status != "" && !slices.Contains([]string{database.StatusDraft, database.StatusPendingReview, database.StatusPublished, database.StatusRejected}, status) {
+		return echo.NewHTTPError(http.StatusBadRequest, "status must be one of draft, pending_review, published or rejected")
+	}
+
+	filters.Page, err = app.readInt(c, "page", 1)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid page number")
+	}
+	filters.PageSize, err = app.readInt(c, "page_size", 20)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid page size")
+	}
+
+	filters.Sort = "-id"
+	filters.SortSafelist = []string{"-id"}
+
+	if err = validation.ValidateFilters(filters); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	courses, metadata, err := app.models.Courses.GetSubmissions(app.contextGetUser(c).ID, status, filters)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting submissions", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, envelope{"courses": courses, "metadata": metadata})
+}
+
+// getSubmission shows a user a course they added, whatever its status, along
+// with the moderators' decisions about it. Other users' courses aren't found.
+func (app *application) getSubmission(c echo.Context) error {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	course, err := app.models.Courses.GetForModeration(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting course", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	if course.SubmittedBy == nil || *course.SubmittedBy != app.contextGetUser(c).ID {
+		return echo.ErrNotFound
+	}
+
+	decisions, _, err := app.models.Courses.GetModerationDecisions(course.ID, database.Filters{Page: 1, PageSize: 100})
+	if err != nil {
+		app.requestLogger(c).Error("Error getting moderation decisions", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, envelope{"course": course, "decisions": decisions})
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/database"
+	"peterweightman.com/runda/internal/validation"
+)
+
+// createSuggestion lets any signed-in user propose a change to a course,
+// which waits for a moderator to accept or reject it. The body is the same
+// shape as a PATCH, plus an optional comment for the reviewer.
+func (app *application) createSuggestion(c echo.Context) error {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	course, err := app.models.Courses.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting course", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	if course.ArchivedAt != nil {
+		return echo.NewHTTPError(http.StatusConflict, "archived courses can't be changed")
+	}
+
+	var input struct {
+		database.CourseChanges
+		Comment string `json:"comment" validate:"max=1000"`
+	}
+
+	err = c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = c.Validate(&input); err != nil {
+		return err
+	}
+
+	diff := input.CourseChanges.Diff(course)
+	if len(diff) == 0 {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "the suggestion doesn't change anything")
+	}
+
+	suggestion := &database.Suggestion{
+		CourseID:    course.ID,
+		UserID:      app.contextGetUser(c).ID,
+		BaseVersion: course.Version,
+		Changes:     input.CourseChanges,
+		Comment:     input.Comment,
+		Diff:        diff,
+	}
+
+	err = app.models.Suggestions.Insert(suggestion)
+	if err != nil {
+		app.requestLogger(c).Error("Error inserting suggestion", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("/v1/suggestions/%d", suggestion.ID))
+	return c.JSON(http.StatusCreated, envelope{"suggestion": suggestion})
+}
+
+// listCourseSuggestions shows reviewers the suggestions for a course, each
+// as a diff against the course as it is now.
+func (app *application) listCourseSuggestions(c echo.Context) error {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	status := c.QueryParam("status")
+	if status == "" {
+		status = database.SuggestionPending
+	}
+
+	if !slices.Contains([]string{database.SuggestionPending, database.SuggestionAccepted, database.SuggestionRejected}, status) {
+		return echo.NewHTTPError(http.StatusBadRequest, "status must be one of pending, accepted or rejected")
+	}
+
+	filters, err := app.readSuggestionFilters(c)
+	if err != nil {
+		return err
+	}
+
+	course, err := app.models.Courses.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting course", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	suggestions, metadata, err := app.models.Suggestions.GetAllForCourse(course.ID, status, filters)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting suggestions", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	for _, suggestion := range suggestions {
+		compareSuggestion(suggestion, course)
+	}
+
+	return c.JSON(http.StatusOK, envelope{"course": course, "suggestions": suggestions, "metadata": metadata})
+}
+
+// listUserSuggestions lets users keep track of the suggestions they've made.
+func (app *application) listUserSuggestions(c echo.Context) error {
+	filters, err := app.readSuggestionFilters(c)
+	if err != nil {
+		return err
+	}
+
+	suggestions, metadata, err := app.models.Suggestions.GetAllForUser(app.contextGetUser(c).ID, filters)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting suggestions", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, envelope{"suggestions": suggestions, "metadata": metadata})
+}
+
+// getSuggestion shows a suggestion to the user who made it, or to a moderator.
+func (app *application) getSuggestion(c echo.Context) error {
+	suggestion, err := app.readSuggestion(c)
+	if err != nil {
+		return err
+	}
+
+	if suggestion.UserID != app.contextGetUser(c).ID {
+		moderator, err := app.userHasPermission(c, database.PermissionCoursesModerate)
+		if err != nil {
+			app.requestLogger(c).Error("Error getting permissions", "error", err)
+			return echo.ErrInternalServerError
+		}
+
+		if !moderator {
+			return echo.ErrNotFound
+		}
+	}
+
+	course, err := app.models.Courses.Get(suggestion.CourseID)
+	switch {
+	case err == nil:
+		compareSuggestion(suggestion, course)
+	case !errors.Is(err, database.ErrRecordNotFound):
+		app.requestLogger(c).Error("Error getting course", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, envelope{"suggestion": suggestion})
+}
+
+// acceptSuggestion applies a suggestion to its course. The update is made
+// against the version the suggestion was based on, so if the course has
+// changed since, it fails with an edit conflict rather than overwriting the
+// newer edit.
+func (app *application) acceptSuggestion(c echo.Context) error {
+	var input struct {
+		Reason string `json:"reason" validate:"max=1000"`
+	}
+
+	// The body is optional when accepting, which Bind allows.
+	err := c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = c.Validate(&input); err != nil {
+		return err
+	}
+
+	suggestion, err := app.readPendingSuggestion(c)
+	if err != nil {
+		return err
+	}
+
+	course, err := app.models.Courses.Get(suggestion.CourseID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.NewHTTPError(http.StatusConflict, "the course is no longer published")
+		default:
+			app.requestLogger(c).Error("Error getting course", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	if course.ArchivedAt != nil {
+		return echo.NewHTTPError(http.StatusConflict, "archived courses can't be changed")
+	}
+
+	// Moderators only review suggestions for courses they could change
+	// themselves, so an organisation's courses are left to its members.
+	if err = app.requireCourseEditor(c, course); err != nil {
+		return err
+	}
+
+	suggestion.Changes.Apply(course)
+	course.Version = suggestion.BaseVersion
+
+	if err = c.Validate(course); err != nil {
+		return err
+	}
+
+	err = app.models.Courses.Update(course)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			return echo.NewHTTPError(http.StatusConflict, "the course has changed since this suggestion was made, so it can only be rejected")
+		default:
+			app.requestLogger(c).Error("Error updating course", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return app.reviewSuggestion(c, suggestion, database.SuggestionAccepted, input.Reason, envelope{"suggestion": suggestion, "course": course})
+}
+
+func (app *application) rejectSuggestion(c echo.Context) error {
+	var input struct {
+		Reason string `json:"reason" validate:"required,max=1000"`
+	}
+
+	err := c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = c.Validate(&input); err != nil {
+		return err
+	}
+
+	suggestion, err := app.readPendingSuggestion(c)
+	if err != nil {
+		return err
+	}
+
+	return app.reviewSuggestion(c, suggestion, database.SuggestionRejected, input.Reason, envelope{"suggestion": suggestion})
+}
+
+func (app *application) reviewSuggestion(c echo.Context, suggestion *database.Suggestion, status, reason string, response envelope) error {
+	reviewer := app.contextGetUser(c)
+
+	suggestion.Status = status
+	suggestion.ReviewerID = &reviewer.ID
+	suggestion.ReviewReason = reason
+
+	err := app.models.Suggestions.Review(suggestion)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			return echo.NewHTTPError(http.StatusConflict, "the suggestion has already been reviewed")
+		default:
+			app.requestLogger(c).Error("Error reviewing suggestion", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// compareSuggestion fills in how a suggestion differs from the course as it
+// is now.
+func compareSuggestion(suggestion *database.Suggestion, course *database.Course) {
+	suggestion.Diff = suggestion.Changes.Diff(course)
+	suggestion.Stale = suggestion.Status == database.SuggestionPending && suggestion.BaseVersion != course.Version
+}
+
+func (app *application) readSuggestion(c echo.Context) (*database.Suggestion, error) {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		return nil, echo.ErrNotFound
+	}
+
+	suggestion, err := app.models.Suggestions.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return nil, echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting suggestion", "error", err)
+			return nil, echo.ErrInternalServerError
+		}
+	}
+
+	return suggestion, nil
+}
+
+func (app *application) readPendingSuggestion(c echo.Context) (*database.Suggestion, error) {
+	suggestion, err := app.readSuggestion(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if suggestion.Status != database.SuggestionPending {
+		return nil, echo.NewHTTPError(http.StatusConflict, "the suggestion has already been reviewed")
+	}
+
+	return suggestion, nil
+}
+
+func (app *application) readSuggestionFilters(c echo.Context) (database.Filters, error) {
+	var filters database.Filters
+	var err error
+
+	filters.Page, err = app.readInt(c, "page", 1)
+	if err != nil {
+		return filters, echo.NewHTTPError(http.StatusBadRequest, "invalid page number")
+	}
+	filters.PageSize, err = app.readInt(c, "page_size", 20)
+	if err != nil {
+		return filters, echo.NewHTTPError(http.StatusBadRequest, "invalid page size")
+	}
+
+	// Suggestions are always newest first.
+	filters.Sort = "-id"
+	filters.SortSafelist = []string{"-id"}
+
+	if err = validation.ValidateFilters(filters); err != nil {
+		return filters, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return filters, nil
+}
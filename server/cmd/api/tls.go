@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"peterweightman.com/runda/internal/certs"
+)
+
+// certPollInterval is how often the certificate files are checked for
+// changes. Certificates are rotated days before they expire, so there's no
+// need to notice quickly; send SIGHUP to reload straight away.
+const certPollInterval = time.Minute
+
+// newTLSConfig only allows TLS 1.2 and above, and for TLS 1.2 only AEAD
+// cipher suites with forward secrecy. TLS 1.3 suites aren't configurable and
+// are all fine.
+func newTLSConfig(reloader *certs.Reloader) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+		CurvePreferences: []tls.CurveID{
+			tls.X25519,
+			tls.CurveP256,
+		},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+}
+
+// watchCertificate reloads the certificate when its files change or the
+// process receives SIGHUP. A failed reload keeps the current certificate, so
+// a half-finished rotation never takes the server down.
+func (app *application) watchCertificate(reloader *certs.Reloader) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+
+	ticker := time.NewTicker(certPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sighup:
+			app.logger.Info("reloading certificate", "reason", "SIGHUP")
+		case <-ticker.C:
+			changed, err := reloader.Changed()
+			if err != nil {
+				app.logger.Error("Error checking certificate files", "error", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			app.logger.Info("reloading certificate", "reason", "files changed")
+		}
+
+		err := reloader.Reload()
+		if err != nil {
+			app.logger.Error("Error reloading certificate, keeping the current one", "error", err)
+			continue
+		}
+
+		app.logger.Info("certificate reloaded", "expiry", reloader.Expiry())
+	}
+}
+
+// serveRedirect sends every plain HTTP request on the redirect port to the
+// same URL on the HTTPS port. 308 keeps the method and body, so API clients
+// that POST to the wrong scheme still work.
+func (app *application) serveRedirect() {
+	s := http.Server{
+		Addr: fmt.Sprintf(":%d", app.config.tls.redirectPort),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = r.Host
+			}
+
+			if app.config.httpPort != 443 {
+				host = net.JoinHostPort(host, strconv.Itoa(app.config.httpPort))
+			}
+
+			http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+		}),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		ErrorLog:     slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+	}
+
+	app.logger.Info("starting redirect server", slog.Group("server", "addr", s.Addr))
+
+	err := s.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		app.logger.Error("redirect server stopped", "error", err)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/database"
+)
+
+func (app *application) createAuthenticationToken(c echo.Context) error {
+	var input struct {
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	err := c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = c.Validate(&input); err != nil {
+		return err
+	}
+
+	user, err := app.models.Users.GetByEmail(input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid authentication credentials")
+		default:
+			app.requestLogger(c).Error("Error getting user", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	match, err := user.Password.Matches(input.Password)
+	if err != nil {
+		app.requestLogger(c).Error("Error checking password", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	if !match {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid authentication credentials")
+	}
+
+	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, database.ScopeAuthentication)
+	if err != nil {
+		app.requestLogger(c).Error("Error creating token", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusCreated, envelope{"authentication_token": token})
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/text/language"
+	"peterweightman.com/runda/internal/database"
+)
+
+func (app *application) listTranslations(c echo.Context) error {
+	course, err := app.readTranslationCourse(c)
+	if err != nil {
+		return err
+	}
+
+	translations, err := app.models.Translations.GetAllForCourse(course.ID)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting translations", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, envelope{"translations": translations})
+}
+
+func (app *application) putTranslation(c echo.Context) error {
+	course, err := app.readTranslationCourse(c)
+	if err != nil {
+		return err
+	}
+
+	if course.ArchivedAt != nil {
+		return echo.NewHTTPError(http.StatusConflict, "archived courses can't be changed")
+	}
+
+	if err = app.requireCourseEditor(c, course); err != nil {
+		return err
+	}
+
+	locale, err := parseLocale(c.Param("locale"))
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	if locale == database.DefaultLocale {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "the course's own name and description are in "+database.DefaultLocale+", so update the course instead")
+	}
+
+	var translation database.Translation
+
+	err = c.Bind(&translation)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	translation.CourseID = course.ID
+	translation.Locale = locale
+
+	if err = c.Validate(&translation); err != nil {
+		return err
+	}
+
+	created, err := app.models.Translations.Put(course, &translation)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			return echo.NewHTTPError(http.StatusConflict, "unable to update the record due to an edit conflict, please try again")
+		default:
+			app.requestLogger(c).Error("Error saving translation", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	if created {
+		return c.JSON(http.StatusCreated, envelope{"translation": translation})
+	}
+
+	return c.JSON(http.StatusOK, envelope{"translation": translation})
+}
+
+func (app *application) deleteTranslation(c echo.Context) error {
+	course, err := app.readTranslationCourse(c)
+	if err != nil {
+		return err
+	}
+
+	if err = app.requireCourseEditor(c, course); err != nil {
+		return err
+	}
+
+	locale, err := parseLocale(c.Param("locale"))
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	err = app.models.Translations.Delete(course, locale)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		case errors.Is(err, database.ErrEditConflict):
+			return echo.NewHTTPError(http.StatusConflict, "unable to update the record due to an edit conflict, please try again")
+		default:
+			app.requestLogger(c).Error("Error deleting translation", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// readTranslationCourse looks up the published course in the :id parameter.
+func (app *application) readTranslationCourse(c echo.Context) (*database.Course, error) {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		return nil, echo.ErrNotFound
+	}
+
+	course, err := app.models.Courses.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return nil, echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting course", "error", err)
+			return nil, echo.ErrInternalServerError
+		}
+	}
+
+	return course, nil
+}
+
+// parseLocale returns the canonical form of a BCP 47 language tag, so that
+// fr-ca and fr-CA are stored and matched as the same locale.
+func parseLocale(s string) (string, error) {
+	tag, err := language.Parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	if tag == language.Und {
+		return "", errors.New("undetermined language")
+	}
+
+	return tag.String(), nil
+}
+
+// readLocales returns the locales the client wants courses in, best first,
+// from the lang query string parameter or else the Accept-Language header.
+// Each regional locale is followed by its language, so fr-CA falls back to fr.
+// The list stops at the default locale, which courses are already written
+// in. An unparseable Accept-Language header is treated as no preference.
+func (app *application) readLocales(c echo.Context) ([]string, error) {
+	var tags []language.Tag
+
+	if lang := c.QueryParam("lang"); lang != "" {
+		tag, err := language.Parse(lang)
+		if err != nil {
+			return nil, err
+		}
+		tags = []language.Tag{tag}
+	} else {
+		tags, _, _ = language.ParseAcceptLanguage(c.Request().Header.Get("Accept-Language"))
+	}
+
+	// The response depends on the header, so caches mustn't share it across
+	// languages.
+	c.Response().Header().Add(echo.HeaderVary, "Accept-Language")
+
+	locales := []string{}
+
+	for _, tag := range tags {
+		base, _ := tag.Base()
+
+		// A * in the header parses as mul, meaning any language.
+		if tag == language.Und || base.String() == "mul" {
+			continue
+		}
+
+		for _, locale := range []string{tag.String(), base.String()} {
+			if locale == database.DefaultLocale {
+				return locales, nil
+			}
+
+			if !slices.Contains(locales, locale) {
+				locales = append(locales, locale)
+			}
+		}
+	}
+
+	return locales, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/database"
+)
+
+func (app *application) registerUser(c echo.Context) error {
+	var input struct {
+		Name     string `json:"name" validate:"required,max=500"`
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required,min=8,max=72"`
+	}
+
+	err := c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = c.Validate(&input); err != nil {
+		return err
+	}
+
+	user := &database.User{
+		Name:  input.Name,
+		Email: input.Email,
+	}
+
+	err = user.Password.Set(input.Password)
+	if err != nil {
+		app.requestLogger(c).Error("Error hashing password", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	err = app.models.Users.Insert(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrDuplicateEmail):
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "a user with this email address already exists")
+		default:
+			app.requestLogger(c).Error("Error inserting user", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.JSON(http.StatusCreated, envelope{"user": user})
+}
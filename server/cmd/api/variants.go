@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/database"
+)
+
+func (app *application) listVariants(c echo.Context) error {
+	course, err := app.readVariantCourse(c)
+	if err != nil {
+		return err
+	}
+
+	variants, err := app.models.Variants.GetAllForCourse(course.ID)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting variants", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, envelope{"variants": variants})
+}
+
+func (app *application) createVariant(c echo.Context) error {
+	course, err := app.readVariantCourse(c)
+	if err != nil {
+		return err
+	}
+
+	if course.ArchivedAt != nil {
+		return echo.NewHTTPError(http.StatusConflict, "archived courses can't be changed")
+	}
+
+	if err = app.requireCourseEditor(c, course); err != nil {
+		return err
+	}
+
+	var variant database.Variant
+
+	err = c.Bind(&variant)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	variant.CourseID = course.ID
+	if variant.Schedule.Days == nil {
+		variant.Schedule.Days = []string{}
+	}
+
+	if err = c.Validate(&variant); err != nil {
+		return err
+	}
+
+	err = app.models.Variants.Insert(&variant)
+	if err != nil {
+		app.requestLogger(c).Error("Error inserting variant", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	c.Response().Header().Set("Location", fmt.Sprintf("/v1/courses/%d/variants/%d", course.ID, variant.ID))
+
+	return c.JSON(http.StatusCreated, envelope{"variant": variant})
+}
+
+func (app *application) getVariant(c echo.Context) error {
+	variant, err := app.readCourseVariant(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, envelope{"variant": variant})
+}
+
+func (app *application) updateVariant(c echo.Context) error {
+	course, err := app.readVariantCourse(c)
+	if err != nil {
+		return err
+	}
+
+	if err = app.requireCourseEditor(c, course); err != nil {
+		return err
+	}
+
+	variant, err := app.readCourseVariant(c)
+	if err != nil {
+		return err
+	}
+
+	var input struct {
+		Name      *string            `json:"name"`
+		DistanceM *int               `json:"distance_m"`
+		Route     *[]database.Coords `json:"route"`
+		Schedule  *database.Schedule `json:"schedule"`
+	}
+
+	err = c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if input.Name != nil {
+		variant.Name = *input.Name
+	}
+
+	if input.DistanceM != nil {
+		variant.DistanceM = input.DistanceM
+	}
+
+	// An empty route removes it.
+	if input.Route != nil {
+		variant.Route = *input.Route
+	}
+
+	if input.Schedule != nil {
+		variant.Schedule = *input.Schedule
+		if variant.Schedule.Days == nil {
+			variant.Schedule.Days = []string{}
+		}
+	}
+
+	if err = c.Validate(variant); err != nil {
+		return err
+	}
+
+	err = app.models.Variants.Update(variant)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			return echo.NewHTTPError(http.StatusConflict, "unable to update the record due to an edit conflict, please try again")
+		default:
+			app.requestLogger(c).Error("Error updating variant", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.JSON(http.StatusOK, envelope{"variant": variant})
+}
+
+func (app *application) deleteVariant(c echo.Context) error {
+	course, err := app.readVariantCourse(c)
+	if err != nil {
+		return err
+	}
+
+	if err = app.requireCourseEditor(c, course); err != nil {
+		return err
+	}
+
+	variant, err := app.readCourseVariant(c)
+	if err != nil {
+		return err
+	}
+
+	err = app.models.Variants.Delete(variant.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error deleting variant", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// readVariantCourse looks up the published course in the :id parameter.
+func (app *application) readVariantCourse(c echo.Context) (*database.Course, error) {
+	courseID, err := app.readIDParam(c)
+	if err != nil {
+		return nil, echo.ErrNotFound
+	}
+
+	course, err := app.models.Courses.Get(courseID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return nil, echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting course", "error", err)
+			return nil, echo.ErrInternalServerError
+		}
+	}
+
+	return course, nil
+}
+
+// readCourseVariant looks up the variant from the :variant_id parameter,
+// making sure it belongs to the course in the :id parameter.
+func (app *application) readCourseVariant(c echo.Context) (*database.Variant, error) {
+	courseID, err := app.readIDParam(c)
+	if err != nil {
+		return nil, echo.ErrNotFound
+	}
+
+	variantID, err := strconv.ParseInt(c.Param("variant_id"), 10, 64)
+	if err != nil || variantID < 1 {
+		return nil, echo.ErrNotFound
+	}
+
+	variant, err := app.models.Variants.Get(variantID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return nil, echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting variant", "error", err)
+			return nil, echo.ErrInternalServerError
+		}
+	}
+
+	if variant.CourseID != courseID {
+		return nil, echo.ErrNotFound
+	}
+
+	return variant, nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"peterweightman.com/runda/internal/database"
+	"peterweightman.com/runda/internal/validation"
+	"peterweightman.com/runda/internal/webhooks"
+)
+
+// runWebhookDispatcher sends webhook deliveries in the background until ctx is
+// done.
+func (app *application) runWebhookDispatcher(ctx context.Context) {
+	dispatcher := &webhooks.Dispatcher{
+		Webhooks: app.models.Webhooks,
+		Events:   app.models.Events,
+		Client: &http.Client{
+			Timeout: 30 * time.Second,
+			// A redirect counts as a failed delivery, rather than following it
+			// somewhere the subscriber didn't register.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Logger:    app.logger,
+		UserAgent: "runda-webhooks/" + version,
+		Interval:  app.config.webhooks.pollInterval,
+		Retention: app.config.webhooks.retention,
+	}
+
+	dispatcher.Run(ctx)
+}
+
+func (app *application) createWebhook(c echo.Context) error {
+	var input struct {
+		URL    string   `json:"url" validate:"required,http_url,max=2000"`
+		Secret string   `json:"secret" validate:"required,min=16,max=256"`
+		Events []string `json:"events" validate:"required,min=1,unique,dive,oneof=course.created course.updated course.archived course.deleted"`
+	}
+
+	err := c.Bind(&input)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = c.Validate(&input); err != nil {
+		return err
+	}
+
+	webhook := &database.Webhook{
+		UserID: app.contextGetUser(c).ID,
+		URL:    input.URL,
+		Secret: input.Secret,
+		Events: input.Events,
+	}
+
+	err = app.models.Webhooks.Insert(webhook)
+	if err != nil {
+		app.requestLogger(c).Error("Error inserting webhook", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("/v1/webhooks/%d", webhook.ID))
+	return c.JSON(http.StatusCreated, envelope{"webhook": webhook})
+}
+
+func (app *application) listWebhooks(c echo.Context) error {
+	webhooks, err := app.models.Webhooks.GetAll()
+	if err != nil {
+		app.requestLogger(c).Error("Error getting webhooks", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, envelope{"webhooks": webhooks})
+}
+
+func (app *application) getWebhook(c echo.Context) error {
+	webhook, err := app.readWebhook(c)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, envelope{"webhook": webhook})
+}
+
+func (app *application) deleteWebhook(c echo.Context) error {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		return echo.ErrNotFound
+	}
+
+	err = app.models.Webhooks.Delete(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error deleting webhook", "error", err)
+			return echo.ErrInternalServerError
+		}
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (app *application) listWebhookDeliveries(c echo.Context) error {
+	webhook, err := app.readWebhook(c)
+	if err != nil {
+		return err
+	}
+
+	var filters database.Filters
+
+	filters.Page, err = app.readInt(c, "page", 1)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid page number")
+	}
+	filters.PageSize, err = app.readInt(c, "page_size", 20)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid page size")
+	}
+
+	// The log is always newest first.
+	filters.Sort = "-id"
+	filters.SortSafelist = []string{"-id"}
+
+	if err = validation.ValidateFilters(filters); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	deliveries, metadata, err := app.models.Webhooks.GetDeliveries(webhook.ID, filters)
+	if err != nil {
+		app.requestLogger(c).Error("Error getting webhook deliveries", "error", err)
+		return echo.ErrInternalServerError
+	}
+
+	return c.JSON(http.StatusOK, envelope{"deliveries": deliveries, "metadata": metadata})
+}
+
+func (app *application) readWebhook(c echo.Context) (*database.Webhook, error) {
+	id, err := app.readIDParam(c)
+	if err != nil {
+		return nil, echo.ErrNotFound
+	}
+
+	webhook, err := app.models.Webhooks.Get(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return nil, echo.ErrNotFound
+		default:
+			app.requestLogger(c).Error("Error getting webhook", "error", err)
+			return nil, echo.ErrInternalServerError
+		}
+	}
+
+	return webhook, nil
+}
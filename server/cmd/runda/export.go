@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"peterweightman.com/runda/internal/courseio"
+	"peterweightman.com/runda/internal/database"
+)
+
+func runExport(app *application, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", courseio.FormatNDJSON, "Output format (csv|ndjson|geojson)")
+	output := fs.String("output", "", "File to write the export to")
+	includeArchived := fs.Bool("include-archived", false, "Include archived courses")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	db, err := app.openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Write to a temporary file alongside the output and only move it into
+	// place once the export is complete, so a failed run never leaves a
+	// truncated snapshot behind.
+	tmp, err := os.CreateTemp(filepath.Dir(*output), ".runda-export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w, err := courseio.NewWriter(tmp, *format)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	models := database.NewModels(db)
+
+	count := 0
+	err = models.Courses.Export(ctx, *includeArchived, func(course *database.Course) error {
+		count++
+		return w.Write(course)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), *output)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("courses exported", "count", count, "format", *format, "output", *output)
+	return nil
+}
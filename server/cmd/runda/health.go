@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4"
+	"peterweightman.com/runda/internal/database"
+)
+
+// runHealth checks that the database is reachable and that its schema is
+// up to date with the migrations in this binary. It exits non-zero if the
+// database can't be reached or a migration has been left dirty.
+func runHealth(app *application, args []string) error {
+	db, err := app.openDB()
+	if err != nil {
+		return fmt.Errorf("database unreachable: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(app.dsn)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	latest, err := database.LatestMigrationVersion()
+	if err != nil {
+		return err
+	}
+
+	version, dirty, err := migrator.Version()
+	switch {
+	case errors.Is(err, migrate.ErrNilVersion):
+		version = 0
+	case err != nil:
+		return err
+	}
+
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty, fix it and run migrate force", version)
+	}
+
+	if version < latest {
+		app.logger.Warn("database schema is behind this binary", "version", version, "latest", latest)
+	}
+
+	app.logger.Info("database ok", "version", version, "latest", latest)
+	return nil
+}
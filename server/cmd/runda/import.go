@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"peterweightman.com/runda/internal/courseio"
+	"peterweightman.com/runda/internal/database"
+)
+
+func runImport(app *application, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := fs.String("file", "", "CSV or GeoJSON file to import")
+	format := fs.String("format", "", "Input format (csv|geojson), detected from the file extension if not set")
+	dryRun := fs.Bool("dry-run", false, "Validate the file and report errors without writing anything")
+	upsert := fs.Bool("upsert", false, "Update existing courses with a matching external_id")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *file == "" {
+		fs.Usage()
+		return errUsage
+	}
+
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(*file)) {
+		case ".csv":
+			*format = courseio.FormatCSV
+		case ".geojson", ".json":
+			*format = courseio.FormatGeoJSON
+		default:
+			return fmt.Errorf("%w: unable to tell the format of %s, set it with -format", errUsage, *file)
+		}
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	records, err := courseio.Read(f, *format)
+	if err != nil {
+		return err
+	}
+
+	courses, rowErrors := courseio.Check(records)
+
+	if len(rowErrors) > 0 {
+		enc := json.NewEncoder(os.Stdout)
+		for _, rowErr := range rowErrors {
+			err := enc.Encode(rowErr)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	app.logger.Info("checked import file", "total", len(records), "valid", len(courses), "invalid", len(rowErrors))
+
+	if *dryRun {
+		return nil
+	}
+
+	if len(rowErrors) > 0 {
+		return errors.New("no courses were imported as some rows are invalid")
+	}
+
+	db, err := app.openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	models := database.NewModels(db)
+
+	result, err := models.Courses.Import(courses, *upsert)
+	if err != nil {
+		var rowErr *database.ImportRowError
+		if errors.As(err, &rowErr) {
+			return fmt.Errorf("no courses were imported: row %d: %w", records[rowErr.Index].Row, rowErr.Err)
+		}
+		return err
+	}
+
+	app.logger.Info("courses imported", "created", result.Created, "updated", result.Updated)
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"peterweightman.com/runda/internal/database"
+	"peterweightman.com/runda/internal/env"
+
+	"github.com/lmittmann/tint"
+)
+
+const version = "0.0.1"
+
+var errUsage = errors.New("usage")
+
+type command struct {
+	name  string
+	usage string
+	run   func(app *application, args []string) error
+}
+
+var commands = []command{
+	{name: "migrate", usage: "migrate up|down -yes|goto VERSION|version|force VERSION", run: runMigrate},
+	{name: "import", usage: "import -file FILE [-format csv|geojson] [-dry-run] [-upsert]", run: runImport},
+	{name: "export", usage: "export -format csv|ndjson|geojson -output FILE [-include-archived]", run: runExport},
+	{name: "users", usage: "users create -name NAME -email EMAIL | users list", run: runUsers},
+	{name: "permissions", usage: "permissions list [EMAIL] | permissions grant|revoke EMAIL CODE...", run: runPermissions},
+	{name: "places", usage: "places backfill [-all]", run: runPlaces},
+	{name: "health", usage: "health", run: runHealth},
+}
+
+type application struct {
+	logger *slog.Logger
+	dsn    string
+}
+
+func main() {
+	logger := slog.New(tint.NewHandler(os.Stderr, &tint.Options{Level: slog.LevelInfo}))
+
+	app := &application{logger: logger}
+
+	flag.StringVar(&app.dsn, "db-dsn", env.GetString("DB_DSN", ""), "PostgreSQL DSN [env var: DB_DSN]")
+	showVersion := flag.Bool("version", false, "display version and exit")
+
+	flag.Usage = usage
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Printf("Version: %s\n", version)
+		return
+	}
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	err := app.dispatch(flag.Arg(0), flag.Args()[1:])
+	if err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			if err != errUsage && err != flag.ErrHelp {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			os.Exit(2)
+		}
+
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func (app *application) dispatch(name string, args []string) error {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd.run(app, args)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage()
+	return errUsage
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: runda [flags] <command> [args]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %s\n", cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// openDB connects to the database without applying migrations, so that
+// commands never change the schema as a side effect.
+func (app *application) openDB() (*database.DB, error) {
+	if app.dsn == "" {
+		return nil, errors.New("DB_DSN environment variable is not set")
+	}
+
+	return database.New(app.dsn, false, database.DbPoolConfig{MaxOpenConns: 2, MaxIdleConns: 2}, app.logger)
+}
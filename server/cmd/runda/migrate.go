@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/golang-migrate/migrate/v4"
+	"peterweightman.com/runda/internal/database"
+)
+
+type migrateLogger struct {
+	app *application
+}
+
+func (l migrateLogger) Printf(format string, v ...any) {
+	l.app.logger.Info(fmt.Sprintf(format, v...))
+}
+
+func (l migrateLogger) Verbose() bool {
+	return false
+}
+
+func runMigrate(app *application, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: migrate needs one of up, down, goto, version or force", errUsage)
+	}
+
+	if app.dsn == "" {
+		return errors.New("DB_DSN environment variable is not set")
+	}
+
+	migrator, err := database.NewMigrator(app.dsn)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	migrator.Log = migrateLogger{app: app}
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		if len(args) < 2 || args[1] != "-yes" {
+			return fmt.Errorf("%w: migrate down reverts every migration and drops all data, pass -yes to confirm", errUsage)
+		}
+		err = migrator.Down()
+	case "goto":
+		var version uint64
+		version, err = readVersionArg(args)
+		if err == nil {
+			err = migrator.Migrate(uint(version))
+		}
+	case "force":
+		var version uint64
+		version, err = readVersionArg(args)
+		if err == nil {
+			err = migrator.Force(int(version))
+		}
+	case "version":
+		return printMigrationVersion(migrator)
+	default:
+		return fmt.Errorf("%w: unknown migrate command %q", errUsage, args[0])
+	}
+
+	switch {
+	case errors.Is(err, migrate.ErrNoChange):
+		app.logger.Info("no migrations to apply")
+	case err != nil:
+		return err
+	}
+
+	return printMigrationVersion(migrator)
+}
+
+func readVersionArg(args []string) (uint64, error) {
+	if len(args) < 2 {
+		return 0, fmt.Errorf("%w: migrate %s needs a version", errUsage, args[0])
+	}
+
+	version, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid version %q", errUsage, args[1])
+	}
+
+	return version, nil
+}
+
+func printMigrationVersion(migrator *migrate.Migrate) error {
+	version, dirty, err := migrator.Version()
+	switch {
+	case errors.Is(err, migrate.ErrNilVersion):
+		fmt.Println("no migrations applied")
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if dirty {
+		fmt.Printf("%d (dirty)\n", version)
+	} else {
+		fmt.Println(version)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"peterweightman.com/runda/internal/database"
+)
+
+func runPermissions(app *application, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: permissions needs one of list, grant or revoke", errUsage)
+	}
+
+	db, err := app.openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	models := database.NewModels(db)
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		permissions, err := models.Permissions.GetAll()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(strings.Join(permissions, "\n"))
+		return nil
+
+	case args[0] == "list" && len(args) == 2:
+		user, err := getUserByEmail(models, args[1])
+		if err != nil {
+			return err
+		}
+
+		permissions, err := models.Permissions.GetAllForUser(user.ID)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(strings.Join(permissions, "\n"))
+		return nil
+
+	case (args[0] == "grant" || args[0] == "revoke") && len(args) >= 3:
+		user, err := getUserByEmail(models, args[1])
+		if err != nil {
+			return err
+		}
+
+		if args[0] == "grant" {
+			err = models.Permissions.AddForUser(user.ID, args[2:]...)
+		} else {
+			err = models.Permissions.RemoveForUser(user.ID, args[2:]...)
+		}
+		if err != nil {
+			return err
+		}
+
+		app.logger.Info("permissions updated", "email", user.Email, "action", args[0], "permissions", args[2:])
+		return nil
+
+	default:
+		return fmt.Errorf("%w: permissions list [EMAIL] | permissions grant|revoke EMAIL CODE...", errUsage)
+	}
+}
+
+func getUserByEmail(models database.Models, email string) (*database.User, error) {
+	user, err := models.Users.GetByEmail(email)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			return nil, fmt.Errorf("no user with email %s", email)
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
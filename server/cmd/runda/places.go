@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"peterweightman.com/runda/internal/database"
+)
+
+func runPlaces(app *application, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: places needs backfill", errUsage)
+	}
+
+	switch args[0] {
+	case "backfill":
+		return runPlacesBackfill(app, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown places command %q", errUsage, args[0])
+	}
+}
+
+// runPlacesBackfill looks up the country, region and timezone of courses
+// from the boundaries built into this binary. The API does the same for
+// courses that have never been looked up when it starts, so this is mostly
+// for redoing every course with -all after the boundaries have changed.
+func runPlacesBackfill(app *application, args []string) error {
+	fs := flag.NewFlagSet("places backfill", flag.ContinueOnError)
+	all := fs.Bool("all", false, "Look up every course, not just those without a place")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	db, err := app.openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	models := database.NewModels(db)
+
+	n, err := models.Courses.BackfillPlaces(ctx, *all)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("course places backfilled", "updated", n)
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"peterweightman.com/runda/internal/database"
+	"peterweightman.com/runda/internal/validation"
+)
+
+func runUsers(app *application, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: users needs one of create or list", errUsage)
+	}
+
+	switch args[0] {
+	case "create":
+		return runUsersCreate(app, args[1:])
+	case "list":
+		return runUsersList(app)
+	default:
+		return fmt.Errorf("%w: unknown users command %q", errUsage, args[0])
+	}
+}
+
+func runUsersCreate(app *application, args []string) error {
+	fs := flag.NewFlagSet("users create", flag.ContinueOnError)
+	name := fs.String("name", "", "Name of the user")
+	email := fs.String("email", "", "Email address of the user")
+	permissions := fs.String("permissions", "", "Comma-separated permissions to grant the user")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of users create (the password is read from the first line of stdin):\n")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *name == "" || !validation.IsEmail(*email) {
+		fs.Usage()
+		return errUsage
+	}
+
+	// Reading the password from stdin keeps it out of the shell history and
+	// the process list.
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		return errors.New("unable to read password from stdin")
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	if !validation.MinRunes(password, 8) || len(password) > 72 {
+		return errors.New("password must be between 8 and 72 bytes long")
+	}
+
+	db, err := app.openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	models := database.NewModels(db)
+
+	user := &database.User{Name: *name, Email: *email}
+
+	err = user.Password.Set(password)
+	if err != nil {
+		return err
+	}
+
+	err = models.Users.Insert(user)
+	if err != nil {
+		return err
+	}
+
+	if *permissions != "" {
+		err = models.Permissions.AddForUser(user.ID, strings.Split(*permissions, ",")...)
+		if err != nil {
+			return fmt.Errorf("user %d created, but granting permissions failed: %w", user.ID, err)
+		}
+	}
+
+	app.logger.Info("user created", "id", user.ID, "email", user.Email)
+	return nil
+}
+
+func runUsersList(app *application) error {
+	db, err := app.openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	models := database.NewModels(db)
+
+	users, err := models.Users.GetAll()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tEMAIL\tCREATED")
+
+	for _, user := range users {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", user.ID, user.Name, user.Email, user.CreatedAt.Format(time.RFC3339))
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,47 @@
+package courseio
+
+import (
+	"errors"
+
+	"peterweightman.com/runda/internal/database"
+	"peterweightman.com/runda/internal/validation"
+)
+
+type RowError struct {
+	Row        int    `json:"row"`
+	ExternalID string `json:"external_id,omitempty"`
+	Error      string `json:"error"`
+}
+
+// Check validates every record the same way a course sent to the API is
+// validated, and makes sure no external ID appears twice. It returns the
+// courses that passed and an error for each record that didn't.
+func Check(records []Record) ([]*database.Course, []RowError) {
+	v := validation.NewValidator()
+	rowErrors := []RowError{}
+	externalIDs := make(map[string]bool, len(records))
+	courses := make([]*database.Course, 0, len(records))
+
+	for _, record := range records {
+		err := record.Err
+		if err == nil {
+			err = v.Struct(record.Course)
+		}
+
+		if err == nil && record.Course.ExternalID != "" {
+			if externalIDs[record.Course.ExternalID] {
+				err = errors.New("external_id appears more than once in the file")
+			}
+			externalIDs[record.Course.ExternalID] = true
+		}
+
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Row: record.Row, ExternalID: record.Course.ExternalID, Error: err.Error()})
+			continue
+		}
+
+		courses = append(courses, record.Course)
+	}
+
+	return courses, rowErrors
+}
@@ -0,0 +1,50 @@
+// Package courseio converts courses to and from the file formats used for bulk
+// imports and exports.
+package courseio
+
+import (
+	"errors"
+	"strings"
+
+	"peterweightman.com/runda/internal/database"
+)
+
+const (
+	FormatCSV     = "csv"
+	FormatGeoJSON = "geojson"
+	FormatNDJSON  = "ndjson"
+)
+
+var ErrUnknownFormat = errors.New("unknown format")
+
+// Record is a single course read from an import file. Err is set when the
+// record itself couldn't be parsed, in which case Course may be incomplete.
+type Record struct {
+	Row    int
+	Course *database.Course
+	Err    error
+}
+
+// csvTagSeparator splits the tags column of a CSV file, as commas are already
+// taken by the fields themselves.
+const csvTagSeparator = ";"
+
+var csvHeader = []string{
+	"id", "external_id", "name", "description", "latitude", "longitude", "tags", "website",
+	"rating_avg", "rating_count", "version", "created_at", "last_updated_at", "archived_at",
+	"distance_m", "surface", "elevation_gain_m", "laps", "terrain_difficulty", "is_certified",
+	"wheelchair", "buggy", "dogs", "toilets", "parking", "showers", "cafe", "station", "bike_racks", "nearest_station",
+}
+
+func splitTags(s string) []string {
+	tags := []string{}
+
+	for _, tag := range strings.Split(s, csvTagSeparator) {
+		tag = strings.TrimSpace(tag)
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
@@ -0,0 +1,225 @@
+package courseio
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"peterweightman.com/runda/internal/database"
+)
+
+// Read parses every course in r, which must be in the given format.
+func Read(r io.Reader, format string) ([]Record, error) {
+	switch format {
+	case FormatCSV:
+		return ReadCSV(r)
+	case FormatGeoJSON:
+		return ReadGeoJSON(r)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// ReadCSV parses a CSV file with a header row. Columns are matched by name, so
+// they may appear in any order, and only name, latitude and longitude are
+// required.
+func ReadCSV(r io.Reader) ([]Record, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("csv file is empty")
+		}
+		return nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	for _, required := range []string{"name", "latitude", "longitude"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv header is missing the %q column", required)
+		}
+	}
+
+	records := []Record{}
+
+	for row := 1; ; row++ {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		record := Record{Row: row, Course: &database.Course{Tags: []string{}}}
+
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+
+			record.Err = parseErr.Err
+			records = append(records, record)
+			continue
+		}
+
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(fields) {
+				return ""
+			}
+			return strings.TrimSpace(fields[i])
+		}
+
+		record.Course.ExternalID = field("external_id")
+		record.Course.Name = field("name")
+		record.Course.Description = field("description")
+		record.Course.Website = field("website")
+		record.Course.Tags = splitTags(field("tags"))
+
+		record.Course.Location.Latitude, err = strconv.ParseFloat(field("latitude"), 64)
+		if err != nil {
+			record.Err = errors.New("latitude must be a number")
+		}
+
+		record.Course.Location.Longitude, err = strconv.ParseFloat(field("longitude"), 64)
+		if err != nil && record.Err == nil {
+			record.Err = errors.New("longitude must be a number")
+		}
+
+		record.Course.Surface = field("surface")
+
+		ints := []struct {
+			column string
+			dest   **int
+		}{
+			{"distance_m", &record.Course.DistanceM},
+			{"elevation_gain_m", &record.Course.ElevationGainM},
+			{"laps", &record.Course.Laps},
+			{"terrain_difficulty", &record.Course.TerrainDifficulty},
+		}
+
+		for _, i := range ints {
+			*i.dest, err = parseOptionalInt(field(i.column))
+			if err != nil && record.Err == nil {
+				record.Err = fmt.Errorf("%s must be a whole number", i.column)
+			}
+		}
+
+		if certified := field("is_certified"); certified != "" {
+			record.Course.IsCertified, err = strconv.ParseBool(certified)
+			if err != nil && record.Err == nil {
+				record.Err = errors.New("is_certified must be true or false")
+			}
+		}
+
+		for _, name := range database.FacilityNames {
+			record.Course.Facilities.Set(name, strings.ToLower(field(name)))
+		}
+		record.Course.Facilities.NearestStation = field("nearest_station")
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func parseOptionalInt(s string) (*int, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+type feature struct {
+	Type       string            `json:"type"`
+	ID         any               `json:"id,omitempty"`
+	Geometry   *geometry         `json:"geometry"`
+	Properties featureProperties `json:"properties"`
+}
+
+type geometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+type featureProperties struct {
+	ExternalID  string   `json:"external_id,omitempty"`
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags"`
+	Website     string   `json:"website,omitempty"`
+	database.CourseAttributes
+	Facilities database.Facilities `json:"facilities"`
+}
+
+// ReadGeoJSON parses a FeatureCollection of Point features, with the course
+// fields held in each feature's properties. A feature's id is used as the
+// external ID if the properties don't include one.
+func ReadGeoJSON(r io.Reader) ([]Record, error) {
+	var collection struct {
+		Type     string            `json:"type"`
+		Features []json.RawMessage `json:"features"`
+	}
+
+	err := json.NewDecoder(r).Decode(&collection)
+	if err != nil {
+		return nil, fmt.Errorf("invalid geojson: %w", err)
+	}
+
+	if collection.Type != "FeatureCollection" {
+		return nil, errors.New("geojson must be a FeatureCollection")
+	}
+
+	records := make([]Record, 0, len(collection.Features))
+
+	for i, raw := range collection.Features {
+		record := Record{Row: i + 1, Course: &database.Course{Tags: []string{}}}
+
+		var f feature
+
+		err := json.Unmarshal(raw, &f)
+		switch {
+		case err != nil:
+			record.Err = fmt.Errorf("invalid feature: %w", err)
+		case f.Geometry == nil || f.Geometry.Type != "Point" || len(f.Geometry.Coordinates) < 2:
+			record.Err = errors.New("feature geometry must be a Point")
+		default:
+			record.Course.ExternalID = f.Properties.ExternalID
+			if record.Course.ExternalID == "" && f.ID != nil {
+				record.Course.ExternalID = fmt.Sprint(f.ID)
+			}
+
+			record.Course.Name = f.Properties.Name
+			record.Course.Description = f.Properties.Description
+			record.Course.Website = f.Properties.Website
+			record.Course.CourseAttributes = f.Properties.CourseAttributes
+			record.Course.Facilities = f.Properties.Facilities
+			record.Course.Location.Longitude = f.Geometry.Coordinates[0]
+			record.Course.Location.Latitude = f.Geometry.Coordinates[1]
+
+			if f.Properties.Tags != nil {
+				record.Course.Tags = f.Properties.Tags
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
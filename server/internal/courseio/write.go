@@ -0,0 +1,190 @@
+package courseio
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"peterweightman.com/runda/internal/database"
+)
+
+// Writer streams courses out one at a time. Close must be called once every
+// course has been written, to finish off the file.
+type Writer interface {
+	Write(course *database.Course) error
+	Close() error
+}
+
+// ContentType returns the media type of files written in the given format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatGeoJSON:
+		return "application/geo+json"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatNDJSON:
+		bw := bufio.NewWriter(w)
+		return &ndjsonWriter{w: bw, enc: json.NewEncoder(bw)}, nil
+	case FormatGeoJSON:
+		return &geojsonWriter{w: bufio.NewWriter(w)}, nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (cw *csvWriter) Write(course *database.Course) error {
+	if !cw.headerWritten {
+		err := cw.w.Write(csvHeader)
+		if err != nil {
+			return err
+		}
+		cw.headerWritten = true
+	}
+
+	archivedAt := ""
+	if course.ArchivedAt != nil {
+		archivedAt = course.ArchivedAt.Format(time.RFC3339)
+	}
+
+	row := []string{
+		strconv.FormatInt(course.ID, 10),
+		course.ExternalID,
+		course.Name,
+		course.Description,
+		strconv.FormatFloat(course.Location.Latitude, 'f', -1, 64),
+		strconv.FormatFloat(course.Location.Longitude, 'f', -1, 64),
+		strings.Join(course.Tags, csvTagSeparator),
+		course.Website,
+		strconv.FormatFloat(course.RatingAvg, 'f', 2, 64),
+		strconv.Itoa(course.RatingCount),
+		strconv.FormatInt(int64(course.Version), 10),
+		course.CreatedAt.Format(time.RFC3339),
+		course.LastUpdatedAt.Format(time.RFC3339),
+		archivedAt,
+		formatOptionalInt(course.DistanceM),
+		course.Surface,
+		formatOptionalInt(course.ElevationGainM),
+		formatOptionalInt(course.Laps),
+		formatOptionalInt(course.TerrainDifficulty),
+		strconv.FormatBool(course.IsCertified),
+	}
+
+	for _, name := range database.FacilityNames {
+		row = append(row, course.Facilities.Get(name))
+	}
+	row = append(row, course.Facilities.NearestStation)
+
+	return cw.w.Write(row)
+}
+
+func formatOptionalInt(i *int) string {
+	if i == nil {
+		return ""
+	}
+
+	return strconv.Itoa(*i)
+}
+
+func (cw *csvWriter) Close() error {
+	if !cw.headerWritten {
+		err := cw.w.Write(csvHeader)
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (nw *ndjsonWriter) Write(course *database.Course) error {
+	return nw.enc.Encode(course)
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nw.w.Flush()
+}
+
+type geojsonFeature struct {
+	Type       string           `json:"type"`
+	ID         int64            `json:"id"`
+	Geometry   geometry         `json:"geometry"`
+	Properties *database.Course `json:"properties"`
+}
+
+// geojsonWriter writes a FeatureCollection by hand, one feature at a time, as
+// encoding/json can only write a whole collection at once.
+type geojsonWriter struct {
+	w       *bufio.Writer
+	started bool
+}
+
+func (gw *geojsonWriter) Write(course *database.Course) error {
+	prefix := ",\n"
+	if !gw.started {
+		prefix = `{"type":"FeatureCollection","features":[` + "\n"
+		gw.started = true
+	}
+
+	_, err := gw.w.WriteString(prefix)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(geojsonFeature{
+		Type: "Feature",
+		ID:   course.ID,
+		Geometry: geometry{
+			Type:        "Point",
+			Coordinates: []float64{course.Location.Longitude, course.Location.Latitude},
+		},
+		Properties: course,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = gw.w.Write(b)
+	return err
+}
+
+func (gw *geojsonWriter) Close() error {
+	if !gw.started {
+		_, err := gw.w.WriteString(`{"type":"FeatureCollection","features":[`)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := gw.w.WriteString("\n]}\n")
+	if err != nil {
+		return err
+	}
+
+	return gw.w.Flush()
+}
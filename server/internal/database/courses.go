@@ -3,51 +3,237 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
+	"peterweightman.com/runda/internal/geo"
+	"peterweightman.com/runda/internal/metrics"
 )
 
 type Course struct {
-	ID            int64     `json:"id"`
-	CreatedAt     time.Time `json:"created_at"`
-	LastUpdatedAt time.Time `json:"last_updated_at"`
-	Version       int32     `json:"version"`
-	Name          string    `json:"name" validate:"required"`
-	Description   string    `json:"description,omitempty"`
-	Location      Coords    `json:"location" validate:"required"`
-	Tags          []string  `json:"tags"`
-	Website       string    `json:"website,omitempty" validate:"optional_uri"`
+	ID            int64      `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastUpdatedAt time.Time  `json:"last_updated_at"`
+	Version       int32      `json:"version"`
+	Name          string     `json:"name" validate:"required"`
+	Description   string     `json:"description,omitempty"`
+	Location      Coords     `json:"location" validate:"required"`
+	Tags          []string   `json:"tags"`
+	Website       string     `json:"website,omitempty" validate:"omitempty,optional_uri"`
+	RatingAvg     float64    `json:"rating_avg"`
+	RatingCount   int        `json:"rating_count"`
+	ExternalID    string     `json:"external_id,omitempty"`
+	ArchivedAt    *time.Time `json:"archived_at,omitempty"`
+	MergedInto    *int64     `json:"merged_into,omitempty"`
+	Status        string     `json:"status" validate:"omitempty,oneof=draft pending_review published rejected"`
+	// CountryCode and Region are worked out from the location whenever it's
+	// written, so any values sent by clients are ignored.
+	CountryCode string `json:"country_code,omitempty"`
+	Region      string `json:"region,omitempty"`
+	// Timezone is also looked up from the location, unless TimezoneSource is
+	// manual. Clients set it to override the lookup, or to "auto" to go back
+	// to it.
+	Timezone       string `json:"timezone,omitempty" validate:"omitempty,iana_timezone|eq=auto"`
+	TimezoneSource string `json:"timezone_source,omitempty"`
+	CourseAttributes
+	Facilities Facilities `json:"facilities"`
+	// Locale is the language of Name and Description, which may come from a
+	// translation on courses read in a requested locale.
+	Locale string `json:"locale,omitempty"`
+	// OrganisationID is the organisation that runs the course. Only its
+	// members can change or delete the course.
+	OrganisationID *int64 `json:"organisation_id,omitempty"`
+	// SubmittedBy is the user who added the course, who can follow it
+	// through moderation. Only single courses, the moderation queue and
+	// GetSubmissions fill it in.
+	SubmittedBy *int64 `json:"-"`
 }
 
+// CourseAttributes describe the route itself. Nil fields aren't known.
+type CourseAttributes struct {
+	DistanceM      *int   `json:"distance_m,omitempty" validate:"omitempty,min=1,max=1000000"`
+	Surface        string `json:"surface,omitempty" validate:"omitempty,oneof=road trail grass track mixed"`
+	ElevationGainM *int   `json:"elevation_gain_m,omitempty" validate:"omitempty,min=0,max=50000"`
+	Laps           *int   `json:"laps,omitempty" validate:"omitempty,min=1,max=1000"`
+	// TerrainDifficulty runs from 1, flat and smooth, to 5, the roughest.
+	TerrainDifficulty *int `json:"terrain_difficulty,omitempty" validate:"omitempty,min=1,max=5"`
+	IsCertified       bool `json:"is_certified"`
+}
+
+// MarshalJSON adds the course's current UTC offset, such as +01:00. It
+// changes with daylight saving time, so it's worked out when the course is
+// sent rather than stored.
+func (c Course) MarshalJSON() ([]byte, error) {
+	type course Course
+
+	out := struct {
+		course
+		UTCOffset string `json:"utc_offset,omitempty"`
+	}{course: course(c)}
+
+	if c.Timezone != "" {
+		location, err := time.LoadLocation(c.Timezone)
+		if err == nil {
+			out.UTCOffset = time.Now().In(location).Format("-07:00")
+		}
+	}
+
+	return json.Marshal(out)
+}
+
+// Courses suggested by the public wait in pending_review until a moderator
+// publishes or rejects them. Drafts are courses moderators have started but
+// aren't ready to publish. Only published courses are visible to everyone.
+const (
+	StatusDraft         = "draft"
+	StatusPendingReview = "pending_review"
+	StatusPublished     = "published"
+	StatusRejected      = "rejected"
+)
+
+const (
+	SurfaceRoad  = "road"
+	SurfaceTrail = "trail"
+	SurfaceGrass = "grass"
+	SurfaceTrack = "track"
+	SurfaceMixed = "mixed"
+)
+
+var Surfaces = []string{SurfaceRoad, SurfaceTrail, SurfaceGrass, SurfaceTrack, SurfaceMixed}
+
+const (
+	TimezoneFromLocation = "location"
+	TimezoneManual       = "manual"
+	// TimezoneAuto is sent as a course's timezone to clear a manual one.
+	TimezoneAuto = "auto"
+)
+
 type CourseModel struct {
-	DB *sqlx.DB
+	DB        *sqlx.DB
+	Places    *geo.Index
+	Timezones *geo.TimezoneIndex
+}
+
+// locate sets the course's country, region and, unless it was set by hand,
+// timezone from its location.
+func (c CourseModel) locate(course *Course) {
+	place := c.Places.Lookup(course.Location.Longitude, course.Location.Latitude)
+	course.CountryCode, course.Region = place.CountryCode, place.Region
+
+	if course.TimezoneSource != TimezoneManual {
+		course.Timezone = c.Timezones.Lookup(course.Location.Longitude, course.Location.Latitude)
+		course.TimezoneSource = TimezoneFromLocation
+	}
 }
 
 func (c CourseModel) Insert(course *Course) error {
+	defer metrics.ObserveQuery("CourseModel.Insert", time.Now())
+
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
 	query := `
-        INSERT INTO courses (name, description, location, tags, website) 
-        VALUES ($1, $2, $3, $4, $5)
+        INSERT INTO courses (name, description, location, tags, website, external_id, status, country_code, region, timezone, timezone_source,
+            distance_m, surface, elevation_gain_m, laps, terrain_difficulty, is_certified, facilities, organisation_id, submitted_by)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, $11, $12, NULLIF($13, ''), $14, $15, $16, $17, $18, $19, $20)
         RETURNING id, created_at, last_updated_at, version`
 
+	c.locate(course)
+	course.Facilities.setUnknown()
+
 	args := []any{
 		course.Name,
 		course.Description,
 		course.Location.AsPostgresPointString(),
 		pq.Array(course.Tags),
 		course.Website,
+		course.ExternalID,
+		course.Status,
+		course.CountryCode,
+		course.Region,
+		course.Timezone,
+		course.TimezoneSource,
+		course.DistanceM,
+		course.Surface,
+		course.ElevationGainM,
+		course.Laps,
+		course.TerrainDifficulty,
+		course.IsCertified,
+		course.Facilities,
+		course.OrganisationID,
+		course.SubmittedBy,
+	}
+
+	tx, err := c.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&course.ID, &course.CreatedAt, &course.LastUpdatedAt, &course.Version)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err, "courses_external_id_key"):
+			return ErrDuplicateExternalID
+		default:
+			return err
+		}
+	}
+
+	// Unpublished courses aren't announced until a moderator approves them.
+	if course.Status == StatusPublished {
+		err = insertEvent(ctx, tx, EventCourseCreated, course.ID, course)
+		if err != nil {
+			return err
+		}
 	}
 
-	return c.DB.QueryRowContext(ctx, query, args...).Scan(&course.ID, &course.CreatedAt, &course.LastUpdatedAt, &course.Version)
+	return tx.Commit()
 }
 
+// Get returns the course only if it's published. Use GetForModeration to see
+// courses in any status.
 func (c CourseModel) Get(id int64) (*Course, error) {
+	defer metrics.ObserveQuery("CourseModel.Get", time.Now())
+
+	return c.get(id, true, nil)
+}
+
+// GetLocalized is Get with the name and description from the first of
+// locales the course has a translation in. Courses read this way shouldn't
+// be written back, as they'd save the translation over the course's own
+// text.
+func (c CourseModel) GetLocalized(id int64, locales []string) (*Course, error) {
+	defer metrics.ObserveQuery("CourseModel.GetLocalized", time.Now())
+
+	return c.get(id, true, locales)
+}
+
+func (c CourseModel) GetForModeration(id int64) (*Course, error) {
+	defer metrics.ObserveQuery("CourseModel.GetForModeration", time.Now())
+
+	return c.get(id, false, nil)
+}
+
+// translationJoin picks each course's translation in the first of the
+// locales in the given parameter that it has one for. Its columns are
+// renamed so they don't clash with the course's own.
+func translationJoin(param int) string {
+	return fmt.Sprintf(`
+        LEFT JOIN LATERAL (
+            SELECT t.name AS translated_name, t.description AS translated_description, t.locale AS translated_locale
+            FROM course_translations t
+            WHERE t.course_id = courses.id AND t.locale = ANY($%[1]d)
+            ORDER BY array_position($%[1]d, t.locale)
+            LIMIT 1
+        ) tr ON true`, param)
+}
+
+func (c CourseModel) get(id int64, publishedOnly bool, locales []string) (*Course, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
@@ -56,13 +242,18 @@ func (c CourseModel) Get(id int64) (*Course, error) {
 	defer cancel()
 
 	query := `
-        SELECT id, created_at, last_updated_at, version, name, description, location[0] as longitude, location[1] as latitude, tags, website
-        FROM courses
-        WHERE id = $1`
+        SELECT id, created_at, last_updated_at, version, COALESCE(tr.translated_name, name), COALESCE(tr.translated_description, description), location[0] as longitude, location[1] as latitude, tags, website, rating_avg, rating_count, COALESCE(external_id, ''), archived_at, merged_into, status, COALESCE(country_code, ''), COALESCE(region, ''), COALESCE(timezone, ''), timezone_source,
+            distance_m, COALESCE(surface, ''), elevation_gain_m, laps, terrain_difficulty, is_certified, facilities, COALESCE(tr.translated_locale, ''), organisation_id, submitted_by
+        FROM courses` + translationJoin(3) + `
+        WHERE id = $1 AND (status = 'published' OR NOT $2)`
+
+	if locales == nil {
+		locales = []string{}
+	}
 
 	var course Course
 
-	err := c.DB.QueryRowContext(ctx, query, id).Scan(
+	err := c.DB.QueryRowContext(ctx, query, id, publishedOnly, pq.Array(locales)).Scan(
 		&course.ID,
 		&course.CreatedAt,
 		&course.LastUpdatedAt,
@@ -73,6 +264,26 @@ func (c CourseModel) Get(id int64) (*Course, error) {
 		&course.Location.Latitude,
 		pq.Array(&course.Tags),
 		&course.Website,
+		&course.RatingAvg,
+		&course.RatingCount,
+		&course.ExternalID,
+		&course.ArchivedAt,
+		&course.MergedInto,
+		&course.Status,
+		&course.CountryCode,
+		&course.Region,
+		&course.Timezone,
+		&course.TimezoneSource,
+		&course.DistanceM,
+		&course.Surface,
+		&course.ElevationGainM,
+		&course.Laps,
+		&course.TerrainDifficulty,
+		&course.IsCertified,
+		&course.Facilities,
+		&course.Locale,
+		&course.OrganisationID,
+		&course.SubmittedBy,
 	)
 
 	if err != nil {
@@ -84,30 +295,64 @@ func (c CourseModel) Get(id int64) (*Course, error) {
 		}
 	}
 
+	if course.Locale == "" {
+		course.Locale = DefaultLocale
+	}
+
 	return &course, nil
 }
 
 func (c CourseModel) Update(course *Course) error {
+	defer metrics.ObserveQuery("CourseModel.Update", time.Now())
+
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
 	query := `
         UPDATE courses 
-        SET name = $1, description = $2, location = $3, tags = $4, website = $5, last_updated_at = now(), version = version + 1
-        WHERE id = $6 AND version = $7
+        SET name = $1, description = $2, location = $3, tags = $4, website = $5, country_code = $6, region = $7,
+            timezone = $8, timezone_source = $9, distance_m = $10, surface = NULLIF($11, ''), elevation_gain_m = $12, laps = $13,
+            terrain_difficulty = $14, is_certified = $15, facilities = $16, organisation_id = $17, last_updated_at = now(), version = version + 1
+        WHERE id = $18 AND version = $19
         RETURNING version, last_updated_at`
 
+	c.locate(course)
+	course.Facilities.setUnknown()
+
 	args := []any{
 		course.Name,
 		course.Description,
 		course.Location.AsPostgresPointString(),
 		pq.Array(course.Tags),
 		course.Website,
+		course.CountryCode,
+		course.Region,
+		course.Timezone,
+		course.TimezoneSource,
+		course.DistanceM,
+		course.Surface,
+		course.ElevationGainM,
+		course.Laps,
+		course.TerrainDifficulty,
+		course.IsCertified,
+		course.Facilities,
+		course.OrganisationID,
 		course.ID,
 		course.Version,
 	}
 
-	err := c.DB.QueryRowContext(ctx, query, args...).Scan(&course.Version, &course.LastUpdatedAt)
+	tx, err := c.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	previous, err := lockPreviousCourse(ctx, tx, "id", course.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&course.Version, &course.LastUpdatedAt)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -117,10 +362,58 @@ func (c CourseModel) Update(course *Course) error {
 		}
 	}
 
-	return nil
+	if course.Status == StatusPublished {
+		err = insertUpdatedEvent(ctx, tx, course, previous)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Archive marks the course as archived, which hides it from exports. Like
+// Update, it fails with ErrEditConflict if the course has changed since it
+// was read.
+func (c CourseModel) Archive(course *Course) error {
+	defer metrics.ObserveQuery("CourseModel.Archive", time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        UPDATE courses
+        SET archived_at = now(), last_updated_at = now(), version = version + 1
+        WHERE id = $1 AND version = $2
+        RETURNING version, last_updated_at, archived_at`
+
+	tx, err := c.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, course.ID, course.Version).Scan(&course.Version, &course.LastUpdatedAt, &course.ArchivedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = insertEvent(ctx, tx, EventCourseArchived, course.ID, course)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (c CourseModel) Delete(id int64) error {
+	defer metrics.ObserveQuery("CourseModel.Delete", time.Now())
+
 	if id < 1 {
 		return ErrRecordNotFound
 	}
@@ -132,7 +425,13 @@ func (c CourseModel) Delete(id int64) error {
         DELETE FROM courses
         WHERE id = $1`
 
-	result, err := c.DB.ExecContext(ctx, query, id)
+	tx, err := c.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -146,22 +445,134 @@ func (c CourseModel) Delete(id int64) error {
 		return ErrRecordNotFound
 	}
 
-	return nil
+	err = insertEvent(ctx, tx, EventCourseDeleted, id, map[string]int64{"id": id})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (c CourseModel) GetAll(name string, tags []string, filters Filters) ([]*Course, Metadata, error) {
+// CourseSearch narrows down the courses listed by GetAll. Zero values match
+// every course. Region is matched case-insensitively. The ranges are
+// inclusive, and courses without the attribute never match them. The variant
+// distance range matches a course if any one of its variants is inside it.
+// Facilities lists names from FacilityNames that a course must have.
+// Locales are the translations to show, best first, and Name is also
+// matched against the course's translations in them. OrganisationID limits
+// the search to the courses the organisation runs.
+type CourseSearch struct {
+	Name        string
+	Tags        []string
+	MinRating   float64
+	CountryCode string
+	Region      string
+
+	DistanceMin, DistanceMax                   *int
+	ElevationGainMin, ElevationGainMax         *int
+	TerrainDifficultyMin, TerrainDifficultyMax *int
+	Surfaces                                   []string
+	Certified                                  *bool
+	VariantDistanceMin, VariantDistanceMax     *int
+	Facilities                                 []string
+	Locales                                    []string
+	OrganisationID                             *int64
+}
+
+// where returns the conditions for the search, with its arguments starting at
+// $1. Either of the place filters can be left out, for facets that count
+// across every value of that field.
+func (s CourseSearch) where(country, region bool) (string, []any) {
+	countryCode, regionName := s.CountryCode, s.Region
+	if !country {
+		countryCode = ""
+	}
+	if !region {
+		regionName = ""
+	}
+
+	where := `
+        WHERE status = 'published'
+        AND archived_at IS NULL
+        AND ($1 = ''
+            OR to_tsvector('english', name) @@ plainto_tsquery('english', $1)
+            OR id IN (
+                SELECT course_id FROM course_translations
+                WHERE locale = ANY($17) AND to_tsvector(search_config, name) @@ plainto_tsquery(search_config, $1)
+            ))
+        AND (tags @> $2 OR $2 = '{}')
+        AND rating_avg >= $3
+        AND (country_code = $4 OR $4 = '')
+        AND (lower(region) = lower($5) OR $5 = '')
+        AND (distance_m >= $6 OR $6::integer IS NULL)
+        AND (distance_m <= $7 OR $7::integer IS NULL)
+        AND (elevation_gain_m >= $8 OR $8::integer IS NULL)
+        AND (elevation_gain_m <= $9 OR $9::integer IS NULL)
+        AND (terrain_difficulty >= $10 OR $10::integer IS NULL)
+        AND (terrain_difficulty <= $11 OR $11::integer IS NULL)
+        AND (surface = ANY($12) OR cardinality($12::text[]) = 0)
+        AND (is_certified = $13 OR $13::boolean IS NULL)
+        AND (($14::integer IS NULL AND $15::integer IS NULL) OR EXISTS (
+            SELECT 1 FROM course_variants v
+            WHERE v.course_id = courses.id
+            AND (v.distance_m >= $14 OR $14::integer IS NULL)
+            AND (v.distance_m <= $15 OR $15::integer IS NULL)
+        ))
+        AND facilities @> $16::jsonb
+        AND (organisation_id = $18 OR $18::bigint IS NULL)`
+
+	// A nil array would be sent as NULL, which matches nothing.
+	surfaces := s.Surfaces
+	if surfaces == nil {
+		surfaces = []string{}
+	}
+
+	locales := s.Locales
+	if locales == nil {
+		locales = []string{}
+	}
+
+	args := []any{
+		s.Name,
+		pq.Array(s.Tags),
+		s.MinRating,
+		countryCode,
+		regionName,
+		s.DistanceMin,
+		s.DistanceMax,
+		s.ElevationGainMin,
+		s.ElevationGainMax,
+		s.TerrainDifficultyMin,
+		s.TerrainDifficultyMax,
+		pq.Array(surfaces),
+		s.Certified,
+		s.VariantDistanceMin,
+		s.VariantDistanceMax,
+		facilityFilter(s.Facilities),
+		pq.Array(locales),
+		s.OrganisationID,
+	}
+
+	return where, args
+}
+
+func (c CourseModel) GetAll(search CourseSearch, filters Filters) ([]*Course, Metadata, error) {
+	defer metrics.ObserveQuery("CourseModel.GetAll", time.Now())
+
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
+	where, args := search.where(true, true)
+
 	query := fmt.Sprintf(`
-		SELECT count(*) OVER(), id, created_at, last_updated_at, version, name, description, location[0] as longitude, location[1] as latitude, tags, website
-		FROM courses
-		WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '') 
-        AND (tags @> $2 OR $2 = '{}')
-		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+		SELECT count(*) OVER(), id, created_at, last_updated_at, version, COALESCE(tr.translated_name, name) AS name, COALESCE(tr.translated_description, description), location[0] as longitude, location[1] as latitude, tags, website, rating_avg, rating_count, COALESCE(external_id, ''), archived_at, status, COALESCE(country_code, ''), COALESCE(region, ''), COALESCE(timezone, ''), timezone_source,
+            distance_m, COALESCE(surface, ''), elevation_gain_m, laps, terrain_difficulty, is_certified, facilities, COALESCE(tr.translated_locale, ''), organisation_id
+		FROM courses %s
+		%s
+		ORDER BY %s %s NULLS LAST, id ASC
+		LIMIT $%d OFFSET $%d`, translationJoin(17), where, courseSortColumn(filters), filters.sortDirection(), len(args)+1, len(args)+2)
 
-	args := []any{name, pq.Array(tags), filters.limit(), filters.offset()}
+	args = append(args, filters.limit(), filters.offset())
 
 	rows, err := c.DB.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -188,11 +599,33 @@ func (c CourseModel) GetAll(name string, tags []string, filters Filters) ([]*Cou
 			&course.Location.Latitude,
 			pq.Array(&course.Tags),
 			&course.Website,
+			&course.RatingAvg,
+			&course.RatingCount,
+			&course.ExternalID,
+			&course.ArchivedAt,
+			&course.Status,
+			&course.CountryCode,
+			&course.Region,
+			&course.Timezone,
+			&course.TimezoneSource,
+			&course.DistanceM,
+			&course.Surface,
+			&course.ElevationGainM,
+			&course.Laps,
+			&course.TerrainDifficulty,
+			&course.IsCertified,
+			&course.Facilities,
+			&course.Locale,
+			&course.OrganisationID,
 		)
 		if err != nil {
 			return nil, Metadata{}, err
 		}
 
+		if course.Locale == "" {
+			course.Locale = DefaultLocale
+		}
+
 		courses = append(courses, &course)
 	}
 
@@ -204,3 +637,494 @@ func (c CourseModel) GetAll(name string, tags []string, filters Filters) ([]*Cou
 
 	return courses, metadata, nil
 }
+
+type FacetCount struct {
+	Value string `json:"value"`
+	// CountryCode is set for regions, as the same name can be used in more
+	// than one country.
+	CountryCode string `json:"country_code,omitempty"`
+	Count       int    `json:"count"`
+}
+
+// Facets counts the courses matching a search by country and by region, most
+// common first.
+type Facets struct {
+	Country []FacetCount `json:"country"`
+	Region  []FacetCount `json:"region"`
+}
+
+// GetFacets counts the courses matching search by place. Each facet ignores
+// its own filter, so that the counts show what choosing another country or
+// region would return, while still applying every other filter.
+func (c CourseModel) GetFacets(search CourseSearch) (Facets, error) {
+	defer metrics.ObserveQuery("CourseModel.GetFacets", time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	facets := Facets{Country: []FacetCount{}, Region: []FacetCount{}}
+
+	countryWhere, args := search.where(false, true)
+	query := fmt.Sprintf(`
+        SELECT country_code, '', count(*)
+        FROM courses
+        %s
+        AND country_code <> ''
+        GROUP BY country_code
+        ORDER BY 3 DESC, 1 ASC`, countryWhere)
+
+	err := c.getFacet(ctx, query, args, &facets.Country)
+	if err != nil {
+		return Facets{}, err
+	}
+
+	regionWhere, args := search.where(true, false)
+	query = fmt.Sprintf(`
+        SELECT region, country_code, count(*)
+        FROM courses
+        %s
+        AND region <> ''
+        GROUP BY country_code, region
+        ORDER BY 3 DESC, 1 ASC, 2 ASC`, regionWhere)
+
+	err = c.getFacet(ctx, query, args, &facets.Region)
+	if err != nil {
+		return Facets{}, err
+	}
+
+	return facets, nil
+}
+
+func (c CourseModel) getFacet(ctx context.Context, query string, args []any, counts *[]FacetCount) error {
+	rows, err := c.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var count FacetCount
+
+		err := rows.Scan(&count.Value, &count.CountryCode, &count.Count)
+		if err != nil {
+			return err
+		}
+
+		*counts = append(*counts, count)
+	}
+
+	return rows.Err()
+}
+
+type ImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+}
+
+// ImportRowError reports which of the courses passed to Import caused it to
+// fail, as an index into the slice.
+type ImportRowError struct {
+	Index int
+	Err   error
+}
+
+func (e *ImportRowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Index+1, e.Err)
+}
+
+func (e *ImportRowError) Unwrap() error {
+	return e.Err
+}
+
+// Import inserts all of the courses in a single transaction, so either every
+// course is written or none are. With upsert set, a course whose external ID
+// already exists replaces the existing row instead of failing the import.
+// New courses are published, but existing ones keep their status, so an
+// upsert never republishes a draft or rejected course behind the moderators'
+// backs. Upserting a course that has been merged into another fails with
+// ErrMergedCourse.
+func (c CourseModel) Import(courses []*Course, upsert bool) (ImportResult, error) {
+	defer metrics.ObserveQuery("CourseModel.Import", time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), importTimeout)
+	defer cancel()
+
+	var result ImportResult
+
+	tx, err := c.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO courses (name, description, location, tags, website, external_id, country_code, region, timezone,
+            distance_m, surface, elevation_gain_m, laps, terrain_difficulty, is_certified, facilities)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, NULLIF($11, ''), $12, $13, $14, $15, $16)
+        RETURNING id, created_at, last_updated_at, version, timezone, timezone_source, status, true`
+
+	if upsert {
+		query = `
+        INSERT INTO courses (name, description, location, tags, website, external_id, country_code, region, timezone,
+            distance_m, surface, elevation_gain_m, laps, terrain_difficulty, is_certified, facilities)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $10, NULLIF($11, ''), $12, $13, $14, $15, $16)
+        ON CONFLICT (external_id) DO UPDATE
+        SET name = EXCLUDED.name, description = EXCLUDED.description, location = EXCLUDED.location,
+            tags = EXCLUDED.tags, website = EXCLUDED.website, country_code = EXCLUDED.country_code,
+            region = EXCLUDED.region, distance_m = EXCLUDED.distance_m, surface = EXCLUDED.surface,
+            elevation_gain_m = EXCLUDED.elevation_gain_m, laps = EXCLUDED.laps, terrain_difficulty = EXCLUDED.terrain_difficulty,
+            is_certified = EXCLUDED.is_certified, facilities = EXCLUDED.facilities, last_updated_at = now(),
+            timezone = CASE WHEN courses.timezone_source = 'manual' THEN courses.timezone ELSE EXCLUDED.timezone END,
+            version = courses.version + 1
+        WHERE courses.merged_into IS NULL
+        RETURNING id, created_at, last_updated_at, version, timezone, timezone_source, status, (xmax = 0)`
+	}
+
+	for i, course := range courses {
+		c.locate(course)
+		course.Facilities.setUnknown()
+
+		args := []any{
+			course.Name,
+			course.Description,
+			course.Location.AsPostgresPointString(),
+			pq.Array(course.Tags),
+			course.Website,
+			course.ExternalID,
+			course.CountryCode,
+			course.Region,
+			course.Timezone,
+			course.DistanceM,
+			course.Surface,
+			course.ElevationGainM,
+			course.Laps,
+			course.TerrainDifficulty,
+			course.IsCertified,
+			course.Facilities,
+		}
+
+		var previous *PreviousCourse
+		var inserted bool
+
+		if upsert && course.ExternalID != "" {
+			previous, err = lockPreviousCourse(ctx, tx, "external_id", course.ExternalID)
+			if err != nil {
+				return ImportResult{}, &ImportRowError{Index: i, Err: err}
+			}
+		}
+
+		// A manual timezone on an existing course survives the upsert, and so
+		// does its status, since moderation decisions are only made through
+		// the moderation queue. Read back whichever were kept.
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&course.ID, &course.CreatedAt, &course.LastUpdatedAt, &course.Version, &course.Timezone, &course.TimezoneSource, &course.Status, &inserted)
+		if err != nil {
+			switch {
+			case isUniqueViolation(err, "courses_external_id_key"):
+				return ImportResult{}, &ImportRowError{Index: i, Err: ErrDuplicateExternalID}
+			case errors.Is(err, sql.ErrNoRows):
+				// The conflicting course has been merged into another, so
+				// the update was skipped by the WHERE clause.
+				return ImportResult{}, &ImportRowError{Index: i, Err: ErrMergedCourse}
+			default:
+				return ImportResult{}, &ImportRowError{Index: i, Err: err}
+			}
+		}
+
+		if inserted {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+
+		if course.Status == StatusPublished {
+			if inserted {
+				err = insertEvent(ctx, tx, EventCourseCreated, course.ID, course)
+			} else {
+				err = insertUpdatedEvent(ctx, tx, course, previous)
+			}
+			if err != nil {
+				return ImportResult{}, err
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	return result, nil
+}
+
+// Export calls fn with every course, reading them in batches through a
+// server-side cursor so that the whole table is never held in memory. It
+// runs until ctx is done rather than using the default timeout, as exports of
+// the full table can take a while.
+func (c CourseModel) Export(ctx context.Context, includeArchived bool, fn func(*Course) error) error {
+	defer metrics.ObserveQuery("CourseModel.Export", time.Now())
+
+	tx, err := c.DB.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// DECLARE doesn't accept bind parameters, so the filter is chosen here.
+	where := "WHERE status = 'published' AND archived_at IS NULL"
+	if includeArchived {
+		where = "WHERE status = 'published'"
+	}
+
+	query := fmt.Sprintf(`
+        DECLARE courses_export NO SCROLL CURSOR FOR
+        SELECT id, created_at, last_updated_at, version, name, description, location[0] as longitude, location[1] as latitude, tags, website, rating_avg, rating_count, COALESCE(external_id, ''), archived_at, status, COALESCE(country_code, ''), COALESCE(region, ''), COALESCE(timezone, ''), timezone_source,
+            distance_m, COALESCE(surface, ''), elevation_gain_m, laps, terrain_difficulty, is_certified, facilities, organisation_id
+        FROM courses
+        %s
+        ORDER BY id ASC`, where)
+
+	_, err = tx.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	for {
+		n, err := c.exportBatch(ctx, tx, fn)
+		if err != nil {
+			return err
+		}
+
+		if n < exportBatchSize {
+			break
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (c CourseModel) exportBatch(ctx context.Context, tx *sqlx.Tx, fn func(*Course) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("FETCH %d FROM courses_export", exportBatchSize))
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	n := 0
+
+	for rows.Next() {
+		var course Course
+
+		err := rows.Scan(
+			&course.ID,
+			&course.CreatedAt,
+			&course.LastUpdatedAt,
+			&course.Version,
+			&course.Name,
+			&course.Description,
+			&course.Location.Longitude,
+			&course.Location.Latitude,
+			pq.Array(&course.Tags),
+			&course.Website,
+			&course.RatingAvg,
+			&course.RatingCount,
+			&course.ExternalID,
+			&course.ArchivedAt,
+			&course.Status,
+			&course.CountryCode,
+			&course.Region,
+			&course.Timezone,
+			&course.TimezoneSource,
+			&course.DistanceM,
+			&course.Surface,
+			&course.ElevationGainM,
+			&course.Laps,
+			&course.TerrainDifficulty,
+			&course.IsCertified,
+			&course.Facilities,
+			&course.OrganisationID,
+		)
+		if err != nil {
+			return n, err
+		}
+
+		err = fn(&course)
+		if err != nil {
+			return n, err
+		}
+
+		n++
+	}
+
+	return n, rows.Err()
+}
+
+// courseSortColumn maps the public sort keys onto their column names, where the
+// two differ.
+func courseSortColumn(filters Filters) string {
+	switch column := filters.sortColumn(); column {
+	case "rating":
+		return "rating_avg"
+	case "distance":
+		return "distance_m"
+	case "elevation_gain":
+		return "elevation_gain_m"
+	default:
+		return column
+	}
+}
+
+const (
+	ChangeUpsert = "upsert"
+	ChangeDelete = "delete"
+)
+
+// CourseChange is an entry in the change feed: either the current state of a
+// course, or a tombstone for one that's been deleted or archived, which
+// clients should drop from their copy.
+type CourseChange struct {
+	Seq    int64  `json:"-"`
+	Type   string `json:"type"`
+	ID     int64  `json:"id"`
+	Reason string `json:"reason,omitempty"`
+	// MergedInto is the course that replaced a merged one.
+	MergedInto *int64  `json:"merged_into,omitempty"`
+	Course     *Course `json:"course,omitempty"`
+}
+
+// GetChanges returns up to limit changes after the change number since, in
+// the order they were made, and whether there are more to come. Only the
+// latest change to each course is kept, so a course edited many times appears
+// once, at its last edit.
+func (c CourseModel) GetChanges(since int64, limit int) ([]*CourseChange, bool, error) {
+	defer metrics.ObserveQuery("CourseModel.GetChanges", time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	// Courses and tombstones share the change sequence, so one ordering over
+	// both is stable no matter how many rows share a last_updated_at second.
+	query := `
+        SELECT change_seq, id, archived_at IS NOT NULL, merged_into, false, created_at, last_updated_at, version, name, description,
+            location[0], location[1], tags, website, rating_avg, rating_count, COALESCE(external_id, ''), archived_at,
+            country_code, region, timezone, timezone_source, distance_m, surface, elevation_gain_m, laps, terrain_difficulty, is_certified,
+            facilities, organisation_id
+        FROM courses
+        WHERE change_seq > $1 AND status = 'published'
+        UNION ALL
+        SELECT change_seq, course_id, false, NULL, true, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL,
+            NULL, NULL, NULL, NULL, NULL, NULL, NULL, NULL
+        FROM course_tombstones
+        WHERE change_seq > $1
+        ORDER BY 1
+        LIMIT $2`
+
+	rows, err := c.DB.QueryContext(ctx, query, since, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer rows.Close()
+
+	changes := []*CourseChange{}
+
+	for rows.Next() {
+		var change CourseChange
+		var archived, deleted bool
+		var course Course
+		var (
+			createdAt, lastUpdatedAt               sql.NullTime
+			version, ratingCount                   sql.NullInt32
+			name, description, website, externalID sql.NullString
+			countryCode, region                    sql.NullString
+			timezone, timezoneSource, surface      sql.NullString
+			isCertified                            sql.NullBool
+			longitude, latitude, ratingAvg         sql.NullFloat64
+		)
+
+		err := rows.Scan(
+			&change.Seq,
+			&change.ID,
+			&archived,
+			&change.MergedInto,
+			&deleted,
+			&createdAt,
+			&lastUpdatedAt,
+			&version,
+			&name,
+			&description,
+			&longitude,
+			&latitude,
+			pq.Array(&course.Tags),
+			&website,
+			&ratingAvg,
+			&ratingCount,
+			&externalID,
+			&course.ArchivedAt,
+			&countryCode,
+			&region,
+			&timezone,
+			&timezoneSource,
+			&course.DistanceM,
+			&surface,
+			&course.ElevationGainM,
+			&course.Laps,
+			&course.TerrainDifficulty,
+			&isCertified,
+			&course.Facilities,
+			&course.OrganisationID,
+		)
+		if err != nil {
+			return nil, false, err
+		}
+
+		switch {
+		case deleted:
+			change.Type = ChangeDelete
+			change.Reason = "deleted"
+		case archived && change.MergedInto != nil:
+			change.Type = ChangeDelete
+			change.Reason = "merged"
+		case archived:
+			change.Type = ChangeDelete
+			change.Reason = "archived"
+		default:
+			course.ID = change.ID
+			course.CreatedAt = createdAt.Time
+			course.LastUpdatedAt = lastUpdatedAt.Time
+			course.Version = version.Int32
+			course.Name = name.String
+			course.Description = description.String
+			course.Location.Longitude = longitude.Float64
+			course.Location.Latitude = latitude.Float64
+			course.Website = website.String
+			course.RatingAvg = ratingAvg.Float64
+			course.RatingCount = int(ratingCount.Int32)
+			course.ExternalID = externalID.String
+			course.CountryCode = countryCode.String
+			course.Region = region.String
+			course.Timezone = timezone.String
+			course.TimezoneSource = timezoneSource.String
+			course.Surface = surface.String
+			course.IsCertified = isCertified.Bool
+			course.Status = StatusPublished
+
+			change.Type = ChangeUpsert
+			change.Course = &course
+		}
+
+		changes = append(changes, &change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(changes) > limit
+	if hasMore {
+		changes = changes[:limit]
+	}
+
+	return changes, hasMore, nil
+}
@@ -6,17 +6,19 @@ import (
 	"log/slog"
 	"time"
 
-	"peterweightman.com/runda/assets"
-
 	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/jmoiron/sqlx"
 
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/lib/pq"
 )
 
-const defaultTimeout = 3 * time.Second
+const (
+	defaultTimeout = 3 * time.Second
+	importTimeout  = 30 * time.Second
+
+	exportBatchSize   = 500
+	backfillBatchSize = 500
+)
 
 type DB struct {
 	*sqlx.DB
@@ -52,12 +54,7 @@ func New(dsn string, automigrate bool, cfg DbPoolConfig, logger *slog.Logger) (*
 	db.SetConnMaxLifetime(cfg.MaxLifetime)
 
 	if automigrate {
-		iofsDriver, err := iofs.New(assets.EmbeddedFiles, "migrations")
-		if err != nil {
-			return nil, err
-		}
-
-		migrator, err := migrate.NewWithSourceInstance("iofs", iofsDriver, dsn)
+		migrator, err := NewMigrator(dsn)
 		if err != nil {
 			return nil, err
 		}
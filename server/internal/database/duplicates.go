@@ -0,0 +1,210 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+	"peterweightman.com/runda/internal/metrics"
+)
+
+// DuplicateCriteria decides when two courses are likely to be the same one:
+// they must be within MaxDistance metres of each other and have names with a
+// trigram similarity of at least MinSimilarity (0 to 1).
+type DuplicateCriteria struct {
+	MaxDistance   float64
+	MinSimilarity float64
+}
+
+type DuplicateCandidate struct {
+	Course     *Course `json:"course"`
+	Distance   float64 `json:"distance_metres"`
+	Similarity float64 `json:"similarity"`
+}
+
+type DuplicatePair struct {
+	Course     *Course `json:"course"`
+	Duplicate  *Course `json:"duplicate"`
+	Distance   float64 `json:"distance_metres"`
+	Similarity float64 `json:"similarity"`
+}
+
+// maxDuplicateCandidates limits how many existing courses are reported as
+// possible duplicates of a new one.
+const maxDuplicateCandidates = 10
+
+// FindDuplicates returns the unarchived courses that look like duplicates of
+// course, most similar first. course itself is left out if it has an ID.
+// Courses waiting for review are included alongside published ones, so that
+// two people submitting the same course don't both end up in the queue.
+func (c CourseModel) FindDuplicates(course *Course, criteria DuplicateCriteria) ([]*DuplicateCandidate, error) {
+	defer metrics.ObserveQuery("CourseModel.FindDuplicates", time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        WITH new AS (SELECT $1::point AS location, $2::text AS name)
+        SELECT id, created_at, last_updated_at, version, courses.name, description, courses.location[0], courses.location[1],
+            tags, website, rating_avg, rating_count, COALESCE(external_id, ''), status,
+            haversine_distance(courses.location, new.location), similarity(courses.name, new.name)
+        FROM courses, new
+        WHERE courses.location <@ distance_box(new.location, $3)
+        AND haversine_distance(courses.location, new.location) <= $3
+        AND similarity(courses.name, new.name) >= $4
+        AND archived_at IS NULL
+        AND status IN ('published', 'pending_review')
+        AND id <> $5
+        ORDER BY 16 DESC, 15 ASC, id ASC
+        LIMIT $6`
+
+	args := []any{
+		course.Location.AsPostgresPointString(),
+		course.Name,
+		criteria.MaxDistance,
+		criteria.MinSimilarity,
+		course.ID,
+		maxDuplicateCandidates,
+	}
+
+	rows, err := c.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	candidates := []*DuplicateCandidate{}
+
+	for rows.Next() {
+		var candidate DuplicateCandidate
+		var existing Course
+
+		err := rows.Scan(
+			&existing.ID,
+			&existing.CreatedAt,
+			&existing.LastUpdatedAt,
+			&existing.Version,
+			&existing.Name,
+			&existing.Description,
+			&existing.Location.Longitude,
+			&existing.Location.Latitude,
+			pq.Array(&existing.Tags),
+			&existing.Website,
+			&existing.RatingAvg,
+			&existing.RatingCount,
+			&existing.ExternalID,
+			&existing.Status,
+			&candidate.Distance,
+			&candidate.Similarity,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		candidate.Course = &existing
+		candidates = append(candidates, &candidate)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+// GetDuplicatePairs returns a page of every pair of unarchived courses, either
+// published or waiting for review, that look like duplicates of each other,
+// most similar first. Each pair appears once, with the older course first.
+func (c CourseModel) GetDuplicatePairs(criteria DuplicateCriteria, filters Filters) ([]*DuplicatePair, Metadata, error) {
+	defer metrics.ObserveQuery("CourseModel.GetDuplicatePairs", time.Now())
+
+	// This compares every course with its neighbours, so give it as long as
+	// an import.
+	ctx, cancel := context.WithTimeout(context.Background(), importTimeout)
+	defer cancel()
+
+	query := `
+        SELECT count(*) OVER(),
+            a.id, a.created_at, a.last_updated_at, a.version, a.name, a.description, a.location[0], a.location[1],
+            a.tags, a.website, a.rating_avg, a.rating_count, COALESCE(a.external_id, ''), a.status,
+            b.id, b.created_at, b.last_updated_at, b.version, b.name, b.description, b.location[0], b.location[1],
+            b.tags, b.website, b.rating_avg, b.rating_count, COALESCE(b.external_id, ''), b.status,
+            haversine_distance(a.location, b.location) AS distance, similarity(a.name, b.name) AS similarity
+        FROM courses a
+        INNER JOIN courses b ON b.id > a.id AND b.location <@ distance_box(a.location, $1)
+        WHERE haversine_distance(a.location, b.location) <= $1
+        AND similarity(a.name, b.name) >= $2
+        AND a.archived_at IS NULL
+        AND b.archived_at IS NULL
+        AND a.status IN ('published', 'pending_review')
+        AND b.status IN ('published', 'pending_review')
+        ORDER BY similarity DESC, distance ASC, a.id ASC, b.id ASC
+        LIMIT $3 OFFSET $4`
+
+	args := []any{criteria.MaxDistance, criteria.MinSimilarity, filters.limit(), filters.offset()}
+
+	rows, err := c.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	pairs := []*DuplicatePair{}
+
+	for rows.Next() {
+		var pair DuplicatePair
+		var a, b Course
+
+		err := rows.Scan(
+			&totalRecords,
+			&a.ID,
+			&a.CreatedAt,
+			&a.LastUpdatedAt,
+			&a.Version,
+			&a.Name,
+			&a.Description,
+			&a.Location.Longitude,
+			&a.Location.Latitude,
+			pq.Array(&a.Tags),
+			&a.Website,
+			&a.RatingAvg,
+			&a.RatingCount,
+			&a.ExternalID,
+			&a.Status,
+			&b.ID,
+			&b.CreatedAt,
+			&b.LastUpdatedAt,
+			&b.Version,
+			&b.Name,
+			&b.Description,
+			&b.Location.Longitude,
+			&b.Location.Latitude,
+			pq.Array(&b.Tags),
+			&b.Website,
+			&b.RatingAvg,
+			&b.RatingCount,
+			&b.ExternalID,
+			&b.Status,
+			&pair.Distance,
+			&pair.Similarity,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		pair.Course = &a
+		pair.Duplicate = &b
+		pairs = append(pairs, &pair)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return pairs, metadata, nil
+}
@@ -0,0 +1,211 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	EventCourseCreated  = "course.created"
+	EventCourseUpdated  = "course.updated"
+	EventCourseArchived = "course.archived"
+	EventCourseDeleted  = "course.deleted"
+)
+
+// EventsChannel is the Postgres NOTIFY channel that new event IDs are sent on.
+const EventsChannel = "course_events"
+
+// EventTypes lists every event type, in the order they're documented.
+var EventTypes = []string{
+	EventCourseCreated,
+	EventCourseUpdated,
+	EventCourseArchived,
+	EventCourseDeleted,
+}
+
+// Event is a change to a course, recorded in the outbox_events table in the
+// same transaction as the change itself, so an event is written if and only
+// if the change is committed.
+type Event struct {
+	ID        int64           `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Type      string          `json:"type"`
+	CourseID  int64           `json:"-"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// PreviousCourse is sent as the "previous" field of a course.updated event
+// when the update could have moved the course or changed its tags, holding
+// what they were before. Streams filtered on them use it to tell clients
+// when a course they were sent has moved out of the filter.
+type PreviousCourse struct {
+	Location Coords   `json:"location"`
+	Tags     []string `json:"tags"`
+}
+
+type eventData struct {
+	Course   any             `json:"course"`
+	Previous *PreviousCourse `json:"previous,omitempty"`
+}
+
+// insertEvent adds an event to the outbox as part of tx. course is what's
+// sent as the event's "course" field: the course itself, or just its ID once
+// it's been deleted.
+func insertEvent(ctx context.Context, tx *sqlx.Tx, eventType string, courseID int64, course any) error {
+	return writeEvent(ctx, tx, eventType, courseID, eventData{Course: course})
+}
+
+// insertUpdatedEvent adds a course.updated event for course to the outbox as
+// part of tx, along with its location and tags from before the update, as
+// read by lockPreviousCourse.
+func insertUpdatedEvent(ctx context.Context, tx *sqlx.Tx, course *Course, previous *PreviousCourse) error {
+	return writeEvent(ctx, tx, EventCourseUpdated, course.ID, eventData{Course: course, Previous: previous})
+}
+
+func writeEvent(ctx context.Context, tx *sqlx.Tx, eventType string, courseID int64, event eventData) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	// Listeners are told the new event's ID when the transaction commits, and
+	// read the event itself from the outbox; nothing is sent if it rolls back.
+	query := `
+        WITH event AS (
+            INSERT INTO outbox_events (type, course_id, data)
+            VALUES ($1, $2, $3)
+            RETURNING id
+        )
+        SELECT pg_notify($4, id::text) FROM event`
+
+	_, err = tx.ExecContext(ctx, query, eventType, courseID, data, EventsChannel)
+	return err
+}
+
+// lockPreviousCourse locks the course whose column matches value for the
+// rest of tx, so it can't change before it's updated, and returns its
+// location and tags. It returns nil if there's no such course.
+func lockPreviousCourse(ctx context.Context, tx *sqlx.Tx, column string, value any) (*PreviousCourse, error) {
+	query := fmt.Sprintf(`SELECT location[0], location[1], tags FROM courses WHERE %s = $1 FOR UPDATE`, column)
+
+	var previous PreviousCourse
+
+	err := tx.QueryRowContext(ctx, query, value).Scan(&previous.Location.Longitude, &previous.Location.Latitude, pq.Array(&previous.Tags))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &previous, nil
+}
+
+type EventModel struct {
+	DB *sqlx.DB
+}
+
+// GetAfter returns up to limit events with IDs greater than id, oldest first.
+//
+// Every event is written in the same transaction as a course write, which
+// holds the change sequence lock until it commits, so event IDs become
+// visible in order and a reader that has seen event N will never later find
+// an event before N appear.
+func (m EventModel) GetAfter(id int64, limit int) ([]*Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT id, created_at, type, course_id, data
+        FROM outbox_events
+        WHERE id > $1
+        ORDER BY id
+        LIMIT $2`
+
+	rows, err := m.DB.QueryContext(ctx, query, id, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := []*Event{}
+
+	for rows.Next() {
+		var event Event
+		var data []byte
+
+		err := rows.Scan(&event.ID, &event.CreatedAt, &event.Type, &event.CourseID, &data)
+		if err != nil {
+			return nil, err
+		}
+
+		event.Data = json.RawMessage(data)
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// LatestID is the ID of the newest event, or 0 if there are none.
+func (m EventModel) LatestID() (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var id int64
+
+	err := m.DB.QueryRowContext(ctx, `SELECT COALESCE(max(id), 0) FROM outbox_events`).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Prune deletes up to limit events that were dispatched to webhooks more
+// than olderThan ago and have no deliveries still pending, along with their
+// finished deliveries, and returns how many it deleted. Streams can't resume
+// from before the oldest event that's left.
+func (m EventModel) Prune(olderThan time.Duration, limit int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        DELETE FROM outbox_events
+        WHERE id IN (
+            SELECT e.id
+            FROM outbox_events e
+            WHERE e.dispatched_at < now() - $1::double precision * interval '1 second'
+            AND NOT EXISTS (
+                SELECT 1 FROM webhook_deliveries d
+                WHERE d.event_id = e.id AND d.status = 'pending'
+            )
+            ORDER BY e.id
+            LIMIT $2
+        )`
+
+	result, err := m.DB.ExecContext(ctx, query, olderThan.Seconds(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Facility values. A facility nobody has reported on is FacilityUnknown,
+// which is kept apart from FacilityNo so that filters only match courses
+// known to have it.
+const (
+	FacilityYes     = "yes"
+	FacilityNo      = "no"
+	FacilityUnknown = "unknown"
+)
+
+// FacilityNames are the facilities that can be filtered on, by their JSON
+// names.
+var FacilityNames = []string{
+	"wheelchair", "buggy", "dogs", "toilets", "parking", "showers", "cafe", "station", "bike_racks",
+}
+
+// Facilities describes what's at and around a course. Dogs is whether dogs
+// are welcome, and Station whether a railway or bus station is within
+// walking distance, which NearestStation can name. Blank values in a change
+// leave the facility as it was.
+type Facilities struct {
+	Wheelchair     string `json:"wheelchair,omitempty" validate:"omitempty,oneof=yes no unknown"`
+	Buggy          string `json:"buggy,omitempty" validate:"omitempty,oneof=yes no unknown"`
+	Dogs           string `json:"dogs,omitempty" validate:"omitempty,oneof=yes no unknown"`
+	Toilets        string `json:"toilets,omitempty" validate:"omitempty,oneof=yes no unknown"`
+	Parking        string `json:"parking,omitempty" validate:"omitempty,oneof=yes no unknown"`
+	Showers        string `json:"showers,omitempty" validate:"omitempty,oneof=yes no unknown"`
+	Cafe           string `json:"cafe,omitempty" validate:"omitempty,oneof=yes no unknown"`
+	Station        string `json:"station,omitempty" validate:"omitempty,oneof=yes no unknown"`
+	BikeRacks      string `json:"bike_racks,omitempty" validate:"omitempty,oneof=yes no unknown"`
+	NearestStation string `json:"nearest_station,omitempty" validate:"max=200"`
+}
+
+type facility struct {
+	name  string
+	value *string
+}
+
+// fields returns the tri-state facilities in the order of FacilityNames.
+func (f *Facilities) fields() []facility {
+	return []facility{
+		{"wheelchair", &f.Wheelchair},
+		{"buggy", &f.Buggy},
+		{"dogs", &f.Dogs},
+		{"toilets", &f.Toilets},
+		{"parking", &f.Parking},
+		{"showers", &f.Showers},
+		{"cafe", &f.Cafe},
+		{"station", &f.Station},
+		{"bike_racks", &f.BikeRacks},
+	}
+}
+
+// Get returns the value of the named facility, or "" for an unknown name.
+func (f Facilities) Get(name string) string {
+	for _, field := range f.fields() {
+		if field.name == name {
+			return *field.value
+		}
+	}
+
+	return ""
+}
+
+// Set sets the named facility, reporting whether the name is known.
+func (f *Facilities) Set(name, value string) bool {
+	for _, field := range f.fields() {
+		if field.name == name {
+			*field.value = value
+			return true
+		}
+	}
+
+	return false
+}
+
+// Apply sets every facility that changes has a value for.
+func (f *Facilities) Apply(changes Facilities) {
+	changed := changes.fields()
+
+	for i, field := range f.fields() {
+		if *changed[i].value != "" {
+			*field.value = *changed[i].value
+		}
+	}
+
+	if changes.NearestStation != "" {
+		f.NearestStation = changes.NearestStation
+	}
+}
+
+// fill returns f with its unknown facilities taken from other.
+func (f Facilities) fill(other Facilities) Facilities {
+	others := other.fields()
+
+	for i, field := range f.fields() {
+		if *field.value == "" || *field.value == FacilityUnknown {
+			*field.value = *others[i].value
+		}
+	}
+
+	if f.NearestStation == "" {
+		f.NearestStation = other.NearestStation
+	}
+
+	f.setUnknown()
+
+	return f
+}
+
+// setUnknown marks the facilities without a value as unknown, so that every
+// course lists them all.
+func (f *Facilities) setUnknown() {
+	for _, field := range f.fields() {
+		if *field.value == "" {
+			*field.value = FacilityUnknown
+		}
+	}
+}
+
+// Value stores the facilities in a jsonb column.
+func (f Facilities) Value() (driver.Value, error) {
+	return json.Marshal(f)
+}
+
+// Scan reads the facilities from a jsonb column. Facilities missing from it
+// are unknown.
+func (f *Facilities) Scan(src any) error {
+	*f = Facilities{}
+
+	switch src := src.(type) {
+	case nil:
+	case []byte:
+		err := json.Unmarshal(src, f)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("cannot scan %T into Facilities", src)
+	}
+
+	f.setUnknown()
+
+	return nil
+}
+
+// facilityFilter matches the courses that have every named facility, as a
+// jsonb containment test that the facilities index can answer.
+type facilityFilter []string
+
+func (ff facilityFilter) Value() (driver.Value, error) {
+	wanted := make(map[string]string, len(ff))
+	for _, name := range ff {
+		wanted[name] = FacilityYes
+	}
+
+	b, err := json.Marshal(wanted)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
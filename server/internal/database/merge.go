@@ -0,0 +1,273 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+	"peterweightman.com/runda/internal/metrics"
+)
+
+// Sides of a merge that a field can be taken from.
+const (
+	MergeTarget = "target"
+	MergeSource = "source"
+	MergeNewest = "newest"
+)
+
+// MergeFields are the fields a merge strategy can choose between.
+var MergeFields = []string{
+	"name", "description", "location", "website", "external_id",
+	"distance_m", "surface", "elevation_gain_m", "laps", "terrain_difficulty", "facilities",
+}
+
+// MergeStrategy says which course each field of a merged course comes from.
+// Default applies to every field not listed in Fields. Tags are always
+// combined, a blank field is always filled from the other course, and the
+// merged course is certified if either course was. Facilities are taken
+// together from one course, with any it doesn't know filled from the other.
+// The merged course stays with the target's organisation, or takes the
+// source's if the target has none.
+type MergeStrategy struct {
+	Default string
+	Fields  map[string]string
+}
+
+func (s MergeStrategy) useSource(field string, target, source *Course) bool {
+	side, ok := s.Fields[field]
+	if !ok {
+		side = s.Default
+	}
+
+	switch side {
+	case MergeSource:
+		return true
+	case MergeNewest:
+		return source.LastUpdatedAt.After(target.LastUpdatedAt)
+	default:
+		return false
+	}
+}
+
+// Apply sets target's fields to the merge of target and source.
+func (s MergeStrategy) Apply(target, source *Course) {
+	pick := func(field string, t, src string) string {
+		if src != "" && (t == "" || s.useSource(field, target, source)) {
+			return src
+		}
+		return t
+	}
+
+	pickInt := func(field string, t, src *int) *int {
+		if src != nil && (t == nil || s.useSource(field, target, source)) {
+			return src
+		}
+		return t
+	}
+
+	name := pick("name", target.Name, source.Name)
+	description := pick("description", target.Description, source.Description)
+	website := pick("website", target.Website, source.Website)
+	externalID := pick("external_id", target.ExternalID, source.ExternalID)
+
+	if s.useSource("location", target, source) {
+		target.Location = source.Location
+	}
+
+	target.Name, target.Description, target.Website, target.ExternalID = name, description, website, externalID
+
+	target.Surface = pick("surface", target.Surface, source.Surface)
+	target.DistanceM = pickInt("distance_m", target.DistanceM, source.DistanceM)
+	target.ElevationGainM = pickInt("elevation_gain_m", target.ElevationGainM, source.ElevationGainM)
+	target.Laps = pickInt("laps", target.Laps, source.Laps)
+	target.TerrainDifficulty = pickInt("terrain_difficulty", target.TerrainDifficulty, source.TerrainDifficulty)
+	target.IsCertified = target.IsCertified || source.IsCertified
+
+	if target.OrganisationID == nil {
+		target.OrganisationID = source.OrganisationID
+	}
+
+	if s.useSource("facilities", target, source) {
+		target.Facilities = source.Facilities.fill(target.Facilities)
+	} else {
+		target.Facilities = target.Facilities.fill(source.Facilities)
+	}
+
+	for _, tag := range source.Tags {
+		if !slices.Contains(target.Tags, tag) {
+			target.Tags = append(target.Tags, tag)
+		}
+	}
+}
+
+// Merge folds source into target, which must already hold the merged fields
+// (see MergeStrategy.Apply). In one transaction it updates target, moves the
+// source's photos, variants, translations and reviews across, and archives
+// the source with a pointer to the target. Where a user reviewed both
+// courses, only their most recently edited review is kept, and where both
+// have a translation into the same locale, the target's is kept. ErrEditConflict is returned if either course has
+// changed since it was read.
+func (c CourseModel) Merge(target, source *Course) error {
+	defer metrics.ObserveQuery("CourseModel.Merge", time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), importTimeout)
+	defer cancel()
+
+	tx, err := c.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Archive the source first, freeing its external ID in case the target
+	// is taking it over.
+	query := `
+        UPDATE courses
+        SET archived_at = now(), merged_into = $1, external_id = NULL, last_updated_at = now(), version = version + 1
+        WHERE id = $2 AND version = $3 AND archived_at IS NULL
+        RETURNING version, last_updated_at, archived_at, merged_into`
+
+	err = tx.QueryRowContext(ctx, query, target.ID, source.ID, source.Version).Scan(&source.Version, &source.LastUpdatedAt, &source.ArchivedAt, &source.MergedInto)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	// Anything merged into the source before now points at the target, so
+	// old links redirect in one step.
+	_, err = tx.ExecContext(ctx, `UPDATE courses SET merged_into = $1 WHERE merged_into = $2`, target.ID, source.ID)
+	if err != nil {
+		return err
+	}
+
+	query = `
+        UPDATE photos
+        SET course_id = $1, position = position + (SELECT COALESCE(max(position), 0) FROM photos WHERE course_id = $1), version = version + 1
+        WHERE course_id = $2`
+
+	_, err = tx.ExecContext(ctx, query, target.ID, source.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE course_variants SET course_id = $1, version = version + 1 WHERE course_id = $2`, target.ID, source.ID)
+	if err != nil {
+		return err
+	}
+
+	query = `
+        UPDATE course_translations
+        SET course_id = $1, last_updated_at = now()
+        WHERE course_id = $2
+        AND locale NOT IN (SELECT locale FROM course_translations WHERE course_id = $1)`
+
+	_, err = tx.ExecContext(ctx, query, target.ID, source.ID)
+	if err != nil {
+		return err
+	}
+
+	query = `
+        DELETE FROM reviews r
+        USING reviews other
+        WHERE r.course_id IN ($1, $2) AND other.course_id IN ($1, $2)
+        AND r.user_id = other.user_id AND r.id <> other.id
+        AND (r.last_updated_at, r.id) < (other.last_updated_at, other.id)`
+
+	_, err = tx.ExecContext(ctx, query, target.ID, source.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE reviews SET course_id = $1 WHERE course_id = $2`, target.ID, source.ID)
+	if err != nil {
+		return err
+	}
+
+	err = updateCourseRating(ctx, tx, source.ID)
+	if err != nil {
+		return err
+	}
+
+	query = `
+        UPDATE courses
+        SET name = $1, description = $2, location = $3, tags = $4, website = $5, external_id = NULLIF($6, ''),
+            country_code = $7, region = $8, timezone = $9, timezone_source = $10, distance_m = $11, surface = NULLIF($12, ''),
+            elevation_gain_m = $13, laps = $14, terrain_difficulty = $15, is_certified = $16, facilities = $17,
+            organisation_id = $18, last_updated_at = now(), version = version + 1
+        WHERE id = $19 AND version = $20 AND archived_at IS NULL
+        RETURNING version, last_updated_at`
+
+	c.locate(target)
+
+	previous, err := lockPreviousCourse(ctx, tx, "id", target.ID)
+	if err != nil {
+		return err
+	}
+
+	args := []any{
+		target.Name,
+		target.Description,
+		target.Location.AsPostgresPointString(),
+		pq.Array(target.Tags),
+		target.Website,
+		target.ExternalID,
+		target.CountryCode,
+		target.Region,
+		target.Timezone,
+		target.TimezoneSource,
+		target.DistanceM,
+		target.Surface,
+		target.ElevationGainM,
+		target.Laps,
+		target.TerrainDifficulty,
+		target.IsCertified,
+		target.Facilities,
+		target.OrganisationID,
+		target.ID,
+		target.Version,
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&target.Version, &target.LastUpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		case isUniqueViolation(err, "courses_external_id_key"):
+			return ErrDuplicateExternalID
+		default:
+			return err
+		}
+	}
+
+	err = updateCourseRating(ctx, tx, target.ID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, `SELECT rating_avg, rating_count FROM courses WHERE id = $1`, target.ID).Scan(&target.RatingAvg, &target.RatingCount)
+	if err != nil {
+		return err
+	}
+
+	source.ExternalID = ""
+	source.RatingAvg, source.RatingCount = 0, 0
+
+	err = insertEvent(ctx, tx, EventCourseArchived, source.ID, source)
+	if err != nil {
+		return err
+	}
+
+	err = insertUpdatedEvent(ctx, tx, target, previous)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
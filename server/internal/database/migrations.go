@@ -0,0 +1,51 @@
+package database
+
+import (
+	"errors"
+	"io/fs"
+
+	"peterweightman.com/runda/assets"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+)
+
+// NewMigrator returns a migrator for the migrations embedded in the binary.
+// The caller is responsible for closing it.
+func NewMigrator(dsn string) (*migrate.Migrate, error) {
+	iofsDriver, err := iofs.New(assets.EmbeddedFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.NewWithSourceInstance("iofs", iofsDriver, dsn)
+}
+
+// LatestMigrationVersion returns the version of the newest embedded
+// migration.
+func LatestMigrationVersion() (uint, error) {
+	iofsDriver, err := iofs.New(assets.EmbeddedFiles, "migrations")
+	if err != nil {
+		return 0, err
+	}
+	defer iofsDriver.Close()
+
+	version, err := iofsDriver.First()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		next, err := iofsDriver.Next(version)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			return version, nil
+		case err != nil:
+			return 0, err
+		}
+
+		version = next
+	}
+}
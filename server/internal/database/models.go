@@ -2,19 +2,70 @@ package database
 
 import (
 	"errors"
+
+	"github.com/lib/pq"
+	"peterweightman.com/runda/internal/geo"
 )
 
 var (
-	ErrRecordNotFound = errors.New("record not found")
-	ErrEditConflict   = errors.New("edit conflict")
+	ErrRecordNotFound      = errors.New("record not found")
+	ErrEditConflict        = errors.New("edit conflict")
+	ErrDuplicateEmail      = errors.New("duplicate email")
+	ErrDuplicateReview     = errors.New("duplicate review")
+	ErrPhotoSetMismatch    = errors.New("photo set mismatch")
+	ErrDuplicateExternalID = errors.New("duplicate external id")
+	ErrUnknownPermission   = errors.New("unknown permission")
+	ErrLastOwner           = errors.New("last owner")
+	ErrOrganisationInUse   = errors.New("organisation in use")
+	ErrMergedCourse        = errors.New("merged course")
 )
 
 type Models struct {
-	Courses CourseModel
+	Courses       CourseModel
+	Events        EventModel
+	Organisations OrganisationModel
+	Permissions   PermissionModel
+	Photos        PhotoModel
+	Reviews       ReviewModel
+	Suggestions   SuggestionModel
+	Tokens        TokenModel
+	Translations  TranslationModel
+	Users         UserModel
+	Variants      VariantModel
+	Webhooks      WebhookModel
 }
 
 func NewModels(db *DB) Models {
 	return Models{
-		Courses: CourseModel{DB: db.DB},
+		Courses:       CourseModel{DB: db.DB, Places: geo.Default(), Timezones: geo.Timezones()},
+		Events:        EventModel{DB: db.DB},
+		Organisations: OrganisationModel{DB: db.DB},
+		Permissions:   PermissionModel{DB: db.DB},
+		Photos:        PhotoModel{DB: db.DB},
+		Reviews:       ReviewModel{DB: db.DB},
+		Suggestions:   SuggestionModel{DB: db.DB},
+		Tokens:        TokenModel{DB: db.DB},
+		Translations:  TranslationModel{DB: db.DB},
+		Users:         UserModel{DB: db.DB},
+		Variants:      VariantModel{DB: db.DB},
+		Webhooks:      WebhookModel{DB: db.DB},
 	}
 }
+
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+
+	return pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+func isForeignKeyViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+
+	return pqErr.Code == "23503" && pqErr.Constraint == constraint
+}
@@ -0,0 +1,238 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"peterweightman.com/runda/internal/metrics"
+)
+
+const (
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+)
+
+// ModerationDecision is an entry in the audit trail of approvals and
+// rejections. ModeratorID is nil once the moderator's account is deleted.
+type ModerationDecision struct {
+	ID          int64     `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	CourseID    int64     `json:"course_id"`
+	ModeratorID *int64    `json:"moderator_id"`
+	Decision    string    `json:"decision"`
+	FromStatus  string    `json:"from_status"`
+	Reason      string    `json:"reason"`
+}
+
+// GetModerationQueue returns a page of the courses in the given status,
+// which is usually pending_review.
+func (c CourseModel) GetModerationQueue(status string, filters Filters) ([]*Course, Metadata, error) {
+	defer metrics.ObserveQuery("CourseModel.GetModerationQueue", time.Now())
+
+	return c.getAnyStatus(`status = $1`, []any{status}, filters)
+}
+
+// GetSubmissions returns a page of the courses the user submitted, in any
+// status, or only those in status if it isn't empty.
+func (c CourseModel) GetSubmissions(userID int64, status string, filters Filters) ([]*Course, Metadata, error) {
+	defer metrics.ObserveQuery("CourseModel.GetSubmissions", time.Now())
+
+	return c.getAnyStatus(`submitted_by = $1 AND (status = $2 OR $2 = '')`, []any{userID, status}, filters)
+}
+
+// getAnyStatus returns a page of the courses matching where, whatever their
+// status. The where clause's parameters are args.
+func (c CourseModel) getAnyStatus(where string, args []any, filters Filters) ([]*Course, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), id, created_at, last_updated_at, version, name, description, location[0] as longitude, location[1] as latitude, tags, website, rating_avg, rating_count, COALESCE(external_id, ''), archived_at, status, COALESCE(country_code, ''), COALESCE(region, ''), COALESCE(timezone, ''), timezone_source,
+            distance_m, COALESCE(surface, ''), elevation_gain_m, laps, terrain_difficulty, is_certified, facilities, organisation_id, submitted_by
+        FROM courses
+        WHERE %s
+        ORDER BY %s %s, id ASC
+        LIMIT $%d OFFSET $%d`, where, filters.sortColumn(), filters.sortDirection(), len(args)+1, len(args)+2)
+
+	args = append(args, filters.limit(), filters.offset())
+
+	rows, err := c.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	courses := []*Course{}
+
+	for rows.Next() {
+		var course Course
+
+		err := rows.Scan(
+			&totalRecords,
+			&course.ID,
+			&course.CreatedAt,
+			&course.LastUpdatedAt,
+			&course.Version,
+			&course.Name,
+			&course.Description,
+			&course.Location.Longitude,
+			&course.Location.Latitude,
+			pq.Array(&course.Tags),
+			&course.Website,
+			&course.RatingAvg,
+			&course.RatingCount,
+			&course.ExternalID,
+			&course.ArchivedAt,
+			&course.Status,
+			&course.CountryCode,
+			&course.Region,
+			&course.Timezone,
+			&course.TimezoneSource,
+			&course.DistanceM,
+			&course.Surface,
+			&course.ElevationGainM,
+			&course.Laps,
+			&course.TerrainDifficulty,
+			&course.IsCertified,
+			&course.Facilities,
+			&course.OrganisationID,
+			&course.SubmittedBy,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		courses = append(courses, &course)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return courses, metadata, nil
+}
+
+// Moderate approves or rejects the course and records the decision in the
+// audit trail, in one transaction. It fails with ErrEditConflict if the course
+// has changed since it was read. Approved courses are announced as created,
+// as that's when they first become visible.
+func (c CourseModel) Moderate(course *Course, decision *ModerationDecision) error {
+	defer metrics.ObserveQuery("CourseModel.Moderate", time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	status := StatusPublished
+	if decision.Decision == DecisionReject {
+		status = StatusRejected
+	}
+
+	tx, err := c.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        UPDATE courses
+        SET status = $1, last_updated_at = now(), version = version + 1
+        WHERE id = $2 AND version = $3
+        RETURNING version, last_updated_at`
+
+	err = tx.QueryRowContext(ctx, query, status, course.ID, course.Version).Scan(&course.Version, &course.LastUpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	decision.CourseID = course.ID
+	decision.FromStatus = course.Status
+	course.Status = status
+
+	query = `
+        INSERT INTO moderation_decisions (course_id, moderator_id, decision, from_status, reason)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at`
+
+	args := []any{decision.CourseID, decision.ModeratorID, decision.Decision, decision.FromStatus, decision.Reason}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&decision.ID, &decision.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if status == StatusPublished {
+		err = insertEvent(ctx, tx, EventCourseCreated, course.ID, course)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetModerationDecisions returns a page of the audit trail, newest first. A
+// courseID of 0 returns decisions about every course.
+func (c CourseModel) GetModerationDecisions(courseID int64, filters Filters) ([]*ModerationDecision, Metadata, error) {
+	defer metrics.ObserveQuery("CourseModel.GetModerationDecisions", time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT count(*) OVER(), id, created_at, course_id, moderator_id, decision, from_status, reason
+        FROM moderation_decisions
+        WHERE course_id = $1 OR $1 = 0
+        ORDER BY id DESC
+        LIMIT $2 OFFSET $3`
+
+	rows, err := c.DB.QueryContext(ctx, query, courseID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	decisions := []*ModerationDecision{}
+
+	for rows.Next() {
+		var decision ModerationDecision
+
+		err := rows.Scan(
+			&totalRecords,
+			&decision.ID,
+			&decision.CreatedAt,
+			&decision.CourseID,
+			&decision.ModeratorID,
+			&decision.Decision,
+			&decision.FromStatus,
+			&decision.Reason,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		decisions = append(decisions, &decision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return decisions, metadata, nil
+}
@@ -0,0 +1,340 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Organisation roles. Owners manage the organisation and its members, and
+// editors can only change its courses.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+)
+
+// Organisation is a club, council or other group that runs courses. Only its
+// members can change or delete the courses it owns.
+type Organisation struct {
+	ID            int64     `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastUpdatedAt time.Time `json:"last_updated_at"`
+	Version       int32     `json:"version"`
+	Name          string    `json:"name" validate:"required,max=200"`
+	Website       string    `json:"website,omitempty" validate:"omitempty,optional_uri"`
+}
+
+type Member struct {
+	UserID    int64     `json:"user_id"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OrganisationModel struct {
+	DB *sqlx.DB
+}
+
+// Insert adds the organisation with ownerID as its first owner.
+func (m OrganisationModel) Insert(organisation *Organisation, ownerID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO organisations (name, website)
+        VALUES ($1, $2)
+        RETURNING id, created_at, last_updated_at, version`
+
+	err = tx.QueryRowContext(ctx, query, organisation.Name, organisation.Website).Scan(&organisation.ID, &organisation.CreatedAt, &organisation.LastUpdatedAt, &organisation.Version)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO organisation_members (organisation_id, user_id, role) VALUES ($1, $2, $3)`, organisation.ID, ownerID, RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m OrganisationModel) Get(id int64) (*Organisation, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT id, created_at, last_updated_at, version, name, website
+        FROM organisations
+        WHERE id = $1`
+
+	var organisation Organisation
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&organisation.ID,
+		&organisation.CreatedAt,
+		&organisation.LastUpdatedAt,
+		&organisation.Version,
+		&organisation.Name,
+		&organisation.Website,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &organisation, nil
+}
+
+func (m OrganisationModel) Update(organisation *Organisation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        UPDATE organisations
+        SET name = $1, website = $2, last_updated_at = now(), version = version + 1
+        WHERE id = $3 AND version = $4
+        RETURNING version, last_updated_at`
+
+	args := []any{organisation.Name, organisation.Website, organisation.ID, organisation.Version}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&organisation.Version, &organisation.LastUpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes the organisation and its memberships. ErrOrganisationInUse
+// is returned while it still runs any courses, which have to be moved to
+// another organisation or deleted first.
+func (m OrganisationModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM organisations WHERE id = $1`, id)
+	if err != nil {
+		switch {
+		case isForeignKeyViolation(err, "courses_organisation_id_fkey"):
+			return ErrOrganisationInUse
+		default:
+			return err
+		}
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetRole returns the user's role in the organisation, or "" if they aren't
+// a member.
+func (m OrganisationModel) GetRole(organisationID, userID int64) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var role string
+
+	err := m.DB.QueryRowContext(ctx, `SELECT role FROM organisation_members WHERE organisation_id = $1 AND user_id = $2`, organisationID, userID).Scan(&role)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return "", nil
+		default:
+			return "", err
+		}
+	}
+
+	return role, nil
+}
+
+func (m OrganisationModel) GetMembers(organisationID int64) ([]*Member, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT organisation_members.user_id, users.name, organisation_members.role, organisation_members.created_at
+        FROM organisation_members
+        INNER JOIN users ON users.id = organisation_members.user_id
+        WHERE organisation_members.organisation_id = $1
+        ORDER BY organisation_members.created_at ASC, organisation_members.user_id ASC`
+
+	rows, err := m.DB.QueryContext(ctx, query, organisationID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	members := []*Member{}
+
+	for rows.Next() {
+		var member Member
+
+		err := rows.Scan(&member.UserID, &member.Name, &member.Role, &member.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, &member)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// SetMember adds the user to the organisation with the role, or changes the
+// role of an existing member. ErrRecordNotFound is returned if the user
+// doesn't exist, and ErrLastOwner if it would leave the organisation without
+// an owner.
+func (m OrganisationModel) SetMember(organisationID, userID int64, role string) (*Member, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := m.lockMembers(ctx, organisationID)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO organisation_members (organisation_id, user_id, role)
+        SELECT $1, users.id, $3 FROM users WHERE users.id = $2
+        ON CONFLICT (organisation_id, user_id) DO UPDATE SET role = EXCLUDED.role
+        RETURNING user_id, (SELECT name FROM users WHERE id = $2), role, created_at`
+
+	var member Member
+
+	err = tx.QueryRowContext(ctx, query, organisationID, userID, role).Scan(&member.UserID, &member.Name, &member.Role, &member.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = checkOwners(ctx, tx, organisationID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+// RemoveMember takes the user out of the organisation. As with SetMember,
+// the last owner can't be removed.
+func (m OrganisationModel) RemoveMember(organisationID, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := m.lockMembers(ctx, organisationID)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM organisation_members WHERE organisation_id = $1 AND user_id = $2`, organisationID, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	err = checkOwners(ctx, tx, organisationID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockMembers starts a transaction holding the organisation's row lock, so
+// that two owners can't demote each other at the same time and leave it with
+// none.
+func (m OrganisationModel) lockMembers(ctx context.Context, organisationID int64) (*sqlx.Tx, error) {
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var id int64
+
+	err = tx.QueryRowContext(ctx, `SELECT id FROM organisations WHERE id = $1 FOR UPDATE`, organisationID).Scan(&id)
+	if err != nil {
+		tx.Rollback()
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return tx, nil
+}
+
+func checkOwners(ctx context.Context, tx *sqlx.Tx, organisationID int64) error {
+	var owners int
+
+	err := tx.QueryRowContext(ctx, `SELECT count(*) FROM organisation_members WHERE organisation_id = $1 AND role = 'owner'`, organisationID).Scan(&owners)
+	if err != nil {
+		return err
+	}
+
+	if owners == 0 {
+		return ErrLastOwner
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"slices"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	PermissionCoursesImport   = "courses:import"
+	PermissionCoursesModerate = "courses:moderate"
+	PermissionWebhooksManage  = "webhooks:manage"
+)
+
+type Permissions []string
+
+func (p Permissions) Include(code string) bool {
+	return slices.Contains(p, code)
+}
+
+type PermissionModel struct {
+	DB *sqlx.DB
+}
+
+func (m PermissionModel) GetAll() (Permissions, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var permissions Permissions
+
+	err := m.DB.SelectContext(ctx, &permissions, `SELECT code FROM permissions ORDER BY code`)
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT permissions.code
+        FROM permissions
+        INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
+        WHERE users_permissions.user_id = $1
+        ORDER BY permissions.code`
+
+	permissions := Permissions{}
+
+	err := m.DB.SelectContext(ctx, &permissions, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// AddForUser grants the given permissions to a user. Granting a permission
+// the user already has is not an error. ErrUnknownPermission is returned if
+// any of the codes don't exist, in which case nothing is granted.
+func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO users_permissions (user_id, permission_id)
+        SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)
+        ON CONFLICT DO NOTHING`
+
+	_, err = tx.ExecContext(ctx, query, userID, pq.Array(codes))
+	if err != nil {
+		return err
+	}
+
+	var known int
+
+	err = tx.GetContext(ctx, &known, `SELECT count(*) FROM permissions WHERE code = ANY($1)`, pq.Array(codes))
+	if err != nil {
+		return err
+	}
+
+	if known != len(codes) {
+		return ErrUnknownPermission
+	}
+
+	return tx.Commit()
+}
+
+func (m PermissionModel) RemoveForUser(userID int64, codes ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        DELETE FROM users_permissions
+        USING permissions
+        WHERE users_permissions.permission_id = permissions.id
+        AND users_permissions.user_id = $1
+        AND permissions.code = ANY($2)`
+
+	_, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
+	return err
+}
@@ -0,0 +1,254 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type Photo struct {
+	ID          int64             `json:"id"`
+	CreatedAt   time.Time         `json:"created_at"`
+	Version     int32             `json:"version"`
+	CourseID    int64             `json:"course_id"`
+	Position    int               `json:"position"`
+	Caption     string            `json:"caption"`
+	ContentType string            `json:"content_type"`
+	SizeBytes   int64             `json:"size_bytes"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	KeyPrefix   string            `json:"-"`
+	Extension   string            `json:"-"`
+	URL         string            `json:"url"`
+	Thumbnails  map[string]string `json:"thumbnails"`
+}
+
+// OriginalKey is the blob store key of the photo as it was uploaded.
+func (p *Photo) OriginalKey() string {
+	return p.KeyPrefix + "/original" + p.Extension
+}
+
+// ThumbnailKey is the blob store key of the named thumbnail size.
+func (p *Photo) ThumbnailKey(size string) string {
+	return p.KeyPrefix + "/" + size + ".jpg"
+}
+
+type PhotoModel struct {
+	DB *sqlx.DB
+}
+
+func (p PhotoModel) Insert(photo *Photo) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        INSERT INTO photos (course_id, position, caption, content_type, size_bytes, width, height, key_prefix, extension)
+        VALUES ($1, (SELECT COALESCE(max(position), 0) + 1 FROM photos WHERE course_id = $1), $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id, created_at, version, position`
+
+	args := []any{
+		photo.CourseID,
+		photo.Caption,
+		photo.ContentType,
+		photo.SizeBytes,
+		photo.Width,
+		photo.Height,
+		photo.KeyPrefix,
+		photo.Extension,
+	}
+
+	return p.DB.QueryRowContext(ctx, query, args...).Scan(&photo.ID, &photo.CreatedAt, &photo.Version, &photo.Position)
+}
+
+func (p PhotoModel) Get(id int64) (*Photo, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT id, created_at, version, course_id, position, caption, content_type, size_bytes, width, height, key_prefix, extension
+        FROM photos
+        WHERE id = $1`
+
+	var photo Photo
+
+	err := p.DB.QueryRowContext(ctx, query, id).Scan(
+		&photo.ID,
+		&photo.CreatedAt,
+		&photo.Version,
+		&photo.CourseID,
+		&photo.Position,
+		&photo.Caption,
+		&photo.ContentType,
+		&photo.SizeBytes,
+		&photo.Width,
+		&photo.Height,
+		&photo.KeyPrefix,
+		&photo.Extension,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &photo, nil
+}
+
+func (p PhotoModel) GetAllForCourse(courseID int64) ([]*Photo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT id, created_at, version, course_id, position, caption, content_type, size_bytes, width, height, key_prefix, extension
+        FROM photos
+        WHERE course_id = $1
+        ORDER BY position ASC, id ASC`
+
+	rows, err := p.DB.QueryContext(ctx, query, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	photos := []*Photo{}
+
+	for rows.Next() {
+		var photo Photo
+
+		err := rows.Scan(
+			&photo.ID,
+			&photo.CreatedAt,
+			&photo.Version,
+			&photo.CourseID,
+			&photo.Position,
+			&photo.Caption,
+			&photo.ContentType,
+			&photo.SizeBytes,
+			&photo.Width,
+			&photo.Height,
+			&photo.KeyPrefix,
+			&photo.Extension,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		photos = append(photos, &photo)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return photos, nil
+}
+
+func (p PhotoModel) Update(photo *Photo) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        UPDATE photos
+        SET caption = $1, version = version + 1
+        WHERE id = $2 AND version = $3
+        RETURNING version`
+
+	err := p.DB.QueryRowContext(ctx, query, photo.Caption, photo.ID, photo.Version).Scan(&photo.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reorder sets the positions of a course's photos to match the order of ids,
+// which must contain every photo of the course exactly once.
+func (p PhotoModel) Reorder(courseID int64, ids []int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := p.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var existing []int64
+
+	err = tx.SelectContext(ctx, &existing, `SELECT id FROM photos WHERE course_id = $1 FOR UPDATE`, courseID)
+	if err != nil {
+		return err
+	}
+
+	if len(existing) != len(ids) {
+		return ErrPhotoSetMismatch
+	}
+
+	query := `
+        UPDATE photos
+        SET position = new.position, version = version + 1
+        FROM unnest($1::bigint[]) WITH ORDINALITY AS new(id, position)
+        WHERE photos.id = new.id AND photos.course_id = $2`
+
+	result, err := tx.ExecContext(ctx, query, pq.Array(ids), courseID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != int64(len(existing)) {
+		return ErrPhotoSetMismatch
+	}
+
+	return tx.Commit()
+}
+
+func (p PhotoModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        DELETE FROM photos
+        WHERE id = $1`
+
+	result, err := p.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+	"peterweightman.com/runda/internal/metrics"
+)
+
+// BackfillPlaces sets the country, region and timezone of every course that
+// hasn't been looked up yet, or with all set, of every course, which is needed
+// after the boundary datasets change. Manual timezones are left alone. It
+// works through the table in batches, each written on its own, so it can be
+// stopped through ctx and picked up again later. It returns how many courses
+// changed.
+//
+// A course edited while its batch is being looked up is skipped, as the edit
+// will have set its place already. The version isn't bumped, since the place
+// is derived from the location rather than being an edit in its own right.
+func (c CourseModel) BackfillPlaces(ctx context.Context, all bool) (int, error) {
+	defer metrics.ObserveQuery("CourseModel.BackfillPlaces", time.Now())
+
+	updated := 0
+	afterID := int64(0)
+
+	for {
+		n, lastID, err := c.backfillBatch(ctx, afterID, all)
+		updated += n
+		if err != nil {
+			return updated, err
+		}
+
+		if lastID == 0 {
+			return updated, nil
+		}
+
+		afterID = lastID
+	}
+}
+
+// backfillBatch looks up the next batch of courses after afterID, returning
+// how many it changed and the last ID it read, which is 0 once there are none
+// left.
+func (c CourseModel) backfillBatch(ctx context.Context, afterID int64, all bool) (int, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT id, version, location[0], location[1]
+        FROM courses
+        WHERE id > $1 AND (country_code IS NULL OR timezone IS NULL OR $2)
+        ORDER BY id ASC
+        LIMIT $3`
+
+	rows, err := c.DB.QueryContext(ctx, query, afterID, all, backfillBatchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	defer rows.Close()
+
+	var (
+		ids                   []int64
+		versions              []int32
+		countryCodes, regions []string
+		timezones             []string
+	)
+
+	for rows.Next() {
+		var id int64
+		var version int32
+		var longitude, latitude float64
+
+		err := rows.Scan(&id, &version, &longitude, &latitude)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		place := c.Places.Lookup(longitude, latitude)
+
+		ids = append(ids, id)
+		versions = append(versions, version)
+		countryCodes = append(countryCodes, place.CountryCode)
+		regions = append(regions, place.Region)
+		timezones = append(timezones, c.Timezones.Lookup(longitude, latitude))
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	if len(ids) == 0 {
+		return 0, 0, nil
+	}
+
+	query = `
+        UPDATE courses
+        SET country_code = places.country_code, region = places.region,
+            timezone = CASE WHEN courses.timezone_source = 'manual' THEN courses.timezone ELSE places.timezone END
+        FROM unnest($1::bigint[], $2::integer[], $3::text[], $4::text[], $5::text[]) AS places (id, version, country_code, region, timezone)
+        WHERE courses.id = places.id AND courses.version = places.version
+        AND ((courses.country_code, courses.region) IS DISTINCT FROM (places.country_code, places.region)
+            OR courses.timezone_source = 'location' AND courses.timezone IS DISTINCT FROM places.timezone)`
+
+	args := []any{pq.Array(ids), pq.Array(versions), pq.Array(countryCodes), pq.Array(regions), pq.Array(timezones)}
+
+	result, err := c.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(n), ids[len(ids)-1], nil
+}
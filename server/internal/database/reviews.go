@@ -0,0 +1,208 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type Review struct {
+	ID            int64     `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastUpdatedAt time.Time `json:"last_updated_at"`
+	Version       int32     `json:"version"`
+	CourseID      int64     `json:"course_id"`
+	UserID        int64     `json:"user_id"`
+	Rating        int       `json:"rating"`
+	Text          string    `json:"text"`
+}
+
+type ReviewModel struct {
+	DB *sqlx.DB
+}
+
+func (r ReviewModel) Insert(review *Review) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO reviews (course_id, user_id, rating, text)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at, last_updated_at, version`
+
+	args := []any{review.CourseID, review.UserID, review.Rating, review.Text}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&review.ID, &review.CreatedAt, &review.LastUpdatedAt, &review.Version)
+	if err != nil {
+		switch {
+		case isUniqueViolation(err, "reviews_course_id_user_id_key"):
+			return ErrDuplicateReview
+		default:
+			return err
+		}
+	}
+
+	err = updateCourseRating(ctx, tx, review.CourseID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r ReviewModel) Get(id int64) (*Review, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT id, created_at, last_updated_at, version, course_id, user_id, rating, text
+        FROM reviews
+        WHERE id = $1`
+
+	var review Review
+
+	err := r.DB.QueryRowContext(ctx, query, id).Scan(
+		&review.ID,
+		&review.CreatedAt,
+		&review.LastUpdatedAt,
+		&review.Version,
+		&review.CourseID,
+		&review.UserID,
+		&review.Rating,
+		&review.Text,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &review, nil
+}
+
+func (r ReviewModel) Update(review *Review) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        UPDATE reviews
+        SET rating = $1, text = $2, last_updated_at = now(), version = version + 1
+        WHERE id = $3 AND version = $4
+        RETURNING version, last_updated_at`
+
+	args := []any{review.Rating, review.Text, review.ID, review.Version}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&review.Version, &review.LastUpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = updateCourseRating(ctx, tx, review.CourseID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r ReviewModel) GetAllForCourse(courseID int64, filters Filters) ([]*Review, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := fmt.Sprintf(`
+        SELECT count(*) OVER(), id, created_at, last_updated_at, version, course_id, user_id, rating, text
+        FROM reviews
+        WHERE course_id = $1
+        ORDER BY %s %s, id ASC
+        LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	rows, err := r.DB.QueryContext(ctx, query, courseID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	reviews := []*Review{}
+
+	for rows.Next() {
+		var review Review
+
+		err := rows.Scan(
+			&totalRecords,
+			&review.ID,
+			&review.CreatedAt,
+			&review.LastUpdatedAt,
+			&review.Version,
+			&review.CourseID,
+			&review.UserID,
+			&review.Rating,
+			&review.Text,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		reviews = append(reviews, &review)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return reviews, metadata, nil
+}
+
+// updateCourseRating recalculates the aggregate rating columns on a course from
+// its reviews. It doesn't touch the course version, as the aggregates aren't
+// something a client can edit and so shouldn't cause edit conflicts.
+//
+// The course row is locked before the reviews are counted. Otherwise two
+// reviews written at once would each count without the other, and whichever
+// committed last would leave the aggregates missing a review.
+func updateCourseRating(ctx context.Context, tx *sqlx.Tx, courseID int64) error {
+	_, err := tx.ExecContext(ctx, `SELECT id FROM courses WHERE id = $1 FOR UPDATE`, courseID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE courses
+        SET rating_avg = COALESCE(r.avg, 0), rating_count = r.count
+        FROM (SELECT avg(rating) AS avg, count(*) AS count FROM reviews WHERE course_id = $1) r
+        WHERE courses.id = $1`
+
+	_, err = tx.ExecContext(ctx, query, courseID)
+	return err
+}
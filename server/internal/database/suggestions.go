@@ -0,0 +1,372 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	SuggestionPending  = "pending"
+	SuggestionAccepted = "accepted"
+	SuggestionRejected = "rejected"
+)
+
+// CourseChanges is a partial change to a course. Nil fields are left as they
+// are.
+type CourseChanges struct {
+	Name        *string   `json:"name,omitempty" validate:"omitempty,min=1"`
+	Description *string   `json:"description,omitempty"`
+	Location    *Coords   `json:"location,omitempty"`
+	Tags        *[]string `json:"tags,omitempty"`
+	Website     *string   `json:"website,omitempty" validate:"omitempty,optional_uri"`
+	Timezone    *string   `json:"timezone,omitempty" validate:"omitempty,iana_timezone|eq=auto"`
+
+	DistanceM         *int    `json:"distance_m,omitempty" validate:"omitempty,min=1,max=1000000"`
+	Surface           *string `json:"surface,omitempty" validate:"omitempty,oneof=road trail grass track mixed"`
+	ElevationGainM    *int    `json:"elevation_gain_m,omitempty" validate:"omitempty,min=0,max=50000"`
+	Laps              *int    `json:"laps,omitempty" validate:"omitempty,min=1,max=1000"`
+	TerrainDifficulty *int    `json:"terrain_difficulty,omitempty" validate:"omitempty,min=1,max=5"`
+	// Facilities only changes the facilities it has values for.
+	Facilities *Facilities `json:"facilities,omitempty"`
+}
+
+// Apply makes the changes to course.
+func (ch CourseChanges) Apply(course *Course) {
+	if ch.Name != nil {
+		course.Name = *ch.Name
+	}
+
+	if ch.Description != nil {
+		course.Description = *ch.Description
+	}
+
+	if ch.Location != nil {
+		course.Location = *ch.Location
+	}
+
+	if ch.Tags != nil {
+		course.Tags = *ch.Tags
+	}
+
+	if ch.Website != nil {
+		course.Website = *ch.Website
+	}
+
+	if ch.DistanceM != nil {
+		course.DistanceM = ch.DistanceM
+	}
+
+	if ch.Surface != nil {
+		course.Surface = *ch.Surface
+	}
+
+	if ch.ElevationGainM != nil {
+		course.ElevationGainM = ch.ElevationGainM
+	}
+
+	if ch.Laps != nil {
+		course.Laps = ch.Laps
+	}
+
+	if ch.TerrainDifficulty != nil {
+		course.TerrainDifficulty = ch.TerrainDifficulty
+	}
+
+	if ch.Facilities != nil {
+		course.Facilities.Apply(*ch.Facilities)
+	}
+
+	switch {
+	case ch.Timezone == nil:
+	case *ch.Timezone == TimezoneAuto:
+		course.TimezoneSource = TimezoneFromLocation
+	default:
+		course.Timezone = *ch.Timezone
+		course.TimezoneSource = TimezoneManual
+	}
+}
+
+// FieldDiff is one field that a change would alter.
+type FieldDiff struct {
+	Field    string `json:"field"`
+	Current  any    `json:"current"`
+	Proposed any    `json:"proposed"`
+}
+
+// Diff lists the fields of course that the changes would alter, leaving out
+// any that already have the proposed value.
+func (ch CourseChanges) Diff(course *Course) []FieldDiff {
+	diff := []FieldDiff{}
+
+	if ch.Name != nil && *ch.Name != course.Name {
+		diff = append(diff, FieldDiff{"name", course.Name, *ch.Name})
+	}
+
+	if ch.Description != nil && *ch.Description != course.Description {
+		diff = append(diff, FieldDiff{"description", course.Description, *ch.Description})
+	}
+
+	if ch.Location != nil && *ch.Location != course.Location {
+		diff = append(diff, FieldDiff{"location", course.Location, *ch.Location})
+	}
+
+	if ch.Tags != nil && !slices.Equal(*ch.Tags, course.Tags) {
+		diff = append(diff, FieldDiff{"tags", course.Tags, *ch.Tags})
+	}
+
+	if ch.Website != nil && *ch.Website != course.Website {
+		diff = append(diff, FieldDiff{"website", course.Website, *ch.Website})
+	}
+
+	ints := []struct {
+		field             string
+		current, proposed *int
+	}{
+		{"distance_m", course.DistanceM, ch.DistanceM},
+		{"elevation_gain_m", course.ElevationGainM, ch.ElevationGainM},
+		{"laps", course.Laps, ch.Laps},
+		{"terrain_difficulty", course.TerrainDifficulty, ch.TerrainDifficulty},
+	}
+
+	for _, f := range ints {
+		if f.proposed != nil && (f.current == nil || *f.current != *f.proposed) {
+			diff = append(diff, FieldDiff{f.field, f.current, *f.proposed})
+		}
+	}
+
+	if ch.Surface != nil && *ch.Surface != course.Surface {
+		diff = append(diff, FieldDiff{"surface", course.Surface, *ch.Surface})
+	}
+
+	if ch.Facilities != nil {
+		for _, name := range FacilityNames {
+			proposed := ch.Facilities.Get(name)
+			if proposed != "" && proposed != course.Facilities.Get(name) {
+				diff = append(diff, FieldDiff{"facilities." + name, course.Facilities.Get(name), proposed})
+			}
+		}
+
+		if ch.Facilities.NearestStation != "" && ch.Facilities.NearestStation != course.Facilities.NearestStation {
+			diff = append(diff, FieldDiff{"facilities.nearest_station", course.Facilities.NearestStation, ch.Facilities.NearestStation})
+		}
+	}
+
+	if ch.Timezone != nil && timezoneChanges(*ch.Timezone, course) {
+		diff = append(diff, FieldDiff{"timezone", course.Timezone, *ch.Timezone})
+	}
+
+	return diff
+}
+
+// timezoneChanges reports whether setting the course's timezone to tz would
+// change it, where "auto" only changes a timezone that was set by hand.
+func timezoneChanges(tz string, course *Course) bool {
+	if tz == TimezoneAuto {
+		return course.TimezoneSource == TimezoneManual
+	}
+
+	return tz != course.Timezone || course.TimezoneSource != TimezoneManual
+}
+
+// Suggestion is a change to a course proposed by someone who can't edit it,
+// made against the course as it was at BaseVersion.
+type Suggestion struct {
+	ID           int64         `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	CourseID     int64         `json:"course_id"`
+	UserID       int64         `json:"user_id"`
+	BaseVersion  int32         `json:"base_version"`
+	Changes      CourseChanges `json:"changes"`
+	Comment      string        `json:"comment,omitempty"`
+	Status       string        `json:"status"`
+	ReviewedAt   *time.Time    `json:"reviewed_at,omitempty"`
+	ReviewerID   *int64        `json:"reviewer_id,omitempty"`
+	ReviewReason string        `json:"review_reason,omitempty"`
+	// Diff and Stale compare the changes with the course as it is now. They
+	// aren't filled in when listing a user's own suggestions.
+	Diff  []FieldDiff `json:"diff,omitempty"`
+	Stale bool        `json:"stale,omitempty"`
+}
+
+type SuggestionModel struct {
+	DB *sqlx.DB
+}
+
+func (m SuggestionModel) Insert(suggestion *Suggestion) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	changes, err := json.Marshal(suggestion.Changes)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO course_suggestions (course_id, user_id, base_version, changes, comment)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at, status`
+
+	args := []any{suggestion.CourseID, suggestion.UserID, suggestion.BaseVersion, changes, suggestion.Comment}
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&suggestion.ID, &suggestion.CreatedAt, &suggestion.Status)
+}
+
+func (m SuggestionModel) Get(id int64) (*Suggestion, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT id, created_at, course_id, user_id, base_version, changes, comment, status, reviewed_at, reviewer_id, review_reason
+        FROM course_suggestions
+        WHERE id = $1`
+
+	var suggestion Suggestion
+	var changes []byte
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&suggestion.ID,
+		&suggestion.CreatedAt,
+		&suggestion.CourseID,
+		&suggestion.UserID,
+		&suggestion.BaseVersion,
+		&changes,
+		&suggestion.Comment,
+		&suggestion.Status,
+		&suggestion.ReviewedAt,
+		&suggestion.ReviewerID,
+		&suggestion.ReviewReason,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	err = json.Unmarshal(changes, &suggestion.Changes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &suggestion, nil
+}
+
+// GetAllForCourse returns a page of the suggestions for a course, newest
+// first. An empty status returns suggestions in every status.
+func (m SuggestionModel) GetAllForCourse(courseID int64, status string, filters Filters) ([]*Suggestion, Metadata, error) {
+	query := `
+        SELECT count(*) OVER(), id, created_at, course_id, user_id, base_version, changes, comment, status, reviewed_at, reviewer_id, review_reason
+        FROM course_suggestions
+        WHERE course_id = $1
+        AND (status = $2 OR $2 = '')
+        ORDER BY id DESC
+        LIMIT $3 OFFSET $4`
+
+	return m.getAll(query, []any{courseID, status, filters.limit(), filters.offset()}, filters)
+}
+
+// GetAllForUser returns a page of the suggestions a user has made, newest
+// first.
+func (m SuggestionModel) GetAllForUser(userID int64, filters Filters) ([]*Suggestion, Metadata, error) {
+	query := `
+        SELECT count(*) OVER(), id, created_at, course_id, user_id, base_version, changes, comment, status, reviewed_at, reviewer_id, review_reason
+        FROM course_suggestions
+        WHERE user_id = $1
+        ORDER BY id DESC
+        LIMIT $2 OFFSET $3`
+
+	return m.getAll(query, []any{userID, filters.limit(), filters.offset()}, filters)
+}
+
+func (m SuggestionModel) getAll(query string, args []any, filters Filters) ([]*Suggestion, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	suggestions := []*Suggestion{}
+
+	for rows.Next() {
+		var suggestion Suggestion
+		var changes []byte
+
+		err := rows.Scan(
+			&totalRecords,
+			&suggestion.ID,
+			&suggestion.CreatedAt,
+			&suggestion.CourseID,
+			&suggestion.UserID,
+			&suggestion.BaseVersion,
+			&changes,
+			&suggestion.Comment,
+			&suggestion.Status,
+			&suggestion.ReviewedAt,
+			&suggestion.ReviewerID,
+			&suggestion.ReviewReason,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		err = json.Unmarshal(changes, &suggestion.Changes)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		suggestions = append(suggestions, &suggestion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return suggestions, metadata, nil
+}
+
+// Review records a reviewer's decision on a pending suggestion, from its
+// Status, ReviewerID and ReviewReason. It fails with ErrEditConflict if the
+// suggestion has already been reviewed.
+func (m SuggestionModel) Review(suggestion *Suggestion) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        UPDATE course_suggestions
+        SET status = $1, reviewer_id = $2, review_reason = $3, reviewed_at = now()
+        WHERE id = $4 AND status = 'pending'
+        RETURNING reviewed_at`
+
+	args := []any{suggestion.Status, suggestion.ReviewerID, suggestion.ReviewReason, suggestion.ID}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&suggestion.ReviewedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
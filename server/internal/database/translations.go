@@ -0,0 +1,218 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultLocale is the language of courses' own names and descriptions,
+// which are shown when no translation matches.
+const DefaultLocale = "en"
+
+// searchConfigs maps languages onto the Postgres text search configurations
+// for them. Languages without one are searched with "simple", which matches
+// words exactly.
+var searchConfigs = map[string]string{
+	"da": "danish",
+	"de": "german",
+	"en": "english",
+	"es": "spanish",
+	"fi": "finnish",
+	"fr": "french",
+	"hu": "hungarian",
+	"it": "italian",
+	"nb": "norwegian",
+	"nl": "dutch",
+	"no": "norwegian",
+	"pt": "portuguese",
+	"ro": "romanian",
+	"ru": "russian",
+	"sv": "swedish",
+	"tr": "turkish",
+}
+
+// SearchConfig returns the text search configuration for a locale, by its
+// language.
+func SearchConfig(locale string) string {
+	lang, _, _ := strings.Cut(strings.ToLower(locale), "-")
+
+	config, ok := searchConfigs[lang]
+	if !ok {
+		return "simple"
+	}
+
+	return config
+}
+
+// Translation is a course's name and description in another locale, which is
+// a BCP 47 language tag such as fr or pt-BR.
+type Translation struct {
+	CourseID      int64     `json:"course_id"`
+	Locale        string    `json:"locale"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastUpdatedAt time.Time `json:"last_updated_at"`
+	Name          string    `json:"name" validate:"required,max=500"`
+	Description   string    `json:"description,omitempty" validate:"max=10000"`
+}
+
+type TranslationModel struct {
+	DB *sqlx.DB
+}
+
+// Put adds or replaces the course's translation into its locale, reporting
+// whether it's new. The course counts as updated, so its version goes up and
+// a course.updated event is recorded, and ErrEditConflict is returned if it
+// has changed since it was read.
+func (m TranslationModel) Put(course *Course, translation *Translation) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO course_translations (course_id, locale, name, description, search_config)
+        VALUES ($1, $2, $3, $4, $5::regconfig)
+        ON CONFLICT (course_id, locale) DO UPDATE
+        SET name = EXCLUDED.name, description = EXCLUDED.description, last_updated_at = now()
+        RETURNING created_at, last_updated_at, (xmax = 0)`
+
+	translation.CourseID = course.ID
+
+	args := []any{
+		translation.CourseID,
+		translation.Locale,
+		translation.Name,
+		translation.Description,
+		SearchConfig(translation.Locale),
+	}
+
+	var created bool
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&translation.CreatedAt, &translation.LastUpdatedAt, &created)
+	if err != nil {
+		return false, err
+	}
+
+	err = touchCourse(ctx, tx, course)
+	if err != nil {
+		return false, err
+	}
+
+	return created, tx.Commit()
+}
+
+func (m TranslationModel) GetAllForCourse(courseID int64) ([]*Translation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT course_id, locale, created_at, last_updated_at, name, description
+        FROM course_translations
+        WHERE course_id = $1
+        ORDER BY locale ASC`
+
+	rows, err := m.DB.QueryContext(ctx, query, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	translations := []*Translation{}
+
+	for rows.Next() {
+		var translation Translation
+
+		err := rows.Scan(
+			&translation.CourseID,
+			&translation.Locale,
+			&translation.CreatedAt,
+			&translation.LastUpdatedAt,
+			&translation.Name,
+			&translation.Description,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		translations = append(translations, &translation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return translations, nil
+}
+
+// Delete removes the course's translation into locale. Like Put, it counts
+// as an update to the course.
+func (m TranslationModel) Delete(course *Course, locale string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM course_translations WHERE course_id = $1 AND locale = $2`, course.ID, locale)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	err = touchCourse(ctx, tx, course)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// touchCourse bumps the version of a course whose translations have changed
+// as part of tx, which also gives it a new change number, and records a
+// course.updated event if it's published, so that the change feed, streams
+// and webhooks all pick up the change. It returns ErrEditConflict if the
+// course has changed since it was read.
+func touchCourse(ctx context.Context, tx *sqlx.Tx, course *Course) error {
+	query := `
+        UPDATE courses
+        SET last_updated_at = now(), version = version + 1
+        WHERE id = $1 AND version = $2
+        RETURNING version, last_updated_at`
+
+	err := tx.QueryRowContext(ctx, query, course.ID, course.Version).Scan(&course.Version, &course.LastUpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	if course.Status == StatusPublished {
+		return insertEvent(ctx, tx, EventCourseUpdated, course.ID, course)
+	}
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// Variant is one of the routes run from a course, such as a junior 2k
+// alongside the main 5k. The course holds what the variants share, like the
+// location and description.
+type Variant struct {
+	ID            int64     `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastUpdatedAt time.Time `json:"last_updated_at"`
+	Version       int32     `json:"version"`
+	CourseID      int64     `json:"course_id"`
+	Name          string    `json:"name" validate:"required,max=200"`
+	DistanceM     *int      `json:"distance_m,omitempty" validate:"omitempty,min=1,max=1000000"`
+	// Route is the path of the variant, in order. It's left out of lists of
+	// variants, as it can be long.
+	Route    []Coords `json:"route,omitempty" validate:"omitempty,min=2,max=10000,dive"`
+	Schedule Schedule `json:"schedule"`
+}
+
+// Schedule is when a variant is run. StartTime is in the course's timezone,
+// as HH:MM.
+type Schedule struct {
+	Days      []string `json:"days" validate:"unique,dive,oneof=mon tue wed thu fri sat sun"`
+	StartTime string   `json:"start_time,omitempty" validate:"omitempty,datetime=15:04"`
+	Notes     string   `json:"notes,omitempty" validate:"max=1000"`
+}
+
+type VariantModel struct {
+	DB *sqlx.DB
+}
+
+func (m VariantModel) Insert(variant *Variant) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	route, err := marshalRoute(variant.Route)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO course_variants (course_id, name, distance_m, route, days, start_time, schedule_notes)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, '')::time, $7)
+        RETURNING id, created_at, last_updated_at, version`
+
+	args := []any{
+		variant.CourseID,
+		variant.Name,
+		variant.DistanceM,
+		route,
+		pq.Array(variant.Schedule.Days),
+		variant.Schedule.StartTime,
+		variant.Schedule.Notes,
+	}
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&variant.ID, &variant.CreatedAt, &variant.LastUpdatedAt, &variant.Version)
+}
+
+func (m VariantModel) Get(id int64) (*Variant, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT id, created_at, last_updated_at, version, course_id, name, distance_m, route, days,
+            COALESCE(to_char(start_time, 'HH24:MI'), ''), schedule_notes
+        FROM course_variants
+        WHERE id = $1`
+
+	var variant Variant
+	var route []byte
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&variant.ID,
+		&variant.CreatedAt,
+		&variant.LastUpdatedAt,
+		&variant.Version,
+		&variant.CourseID,
+		&variant.Name,
+		&variant.DistanceM,
+		&route,
+		pq.Array(&variant.Schedule.Days),
+		&variant.Schedule.StartTime,
+		&variant.Schedule.Notes,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if route != nil {
+		err = json.Unmarshal(route, &variant.Route)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &variant, nil
+}
+
+// GetAllForCourse returns the course's variants, shortest first, without
+// their routes.
+func (m VariantModel) GetAllForCourse(courseID int64) ([]*Variant, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT id, created_at, last_updated_at, version, course_id, name, distance_m, days,
+            COALESCE(to_char(start_time, 'HH24:MI'), ''), schedule_notes
+        FROM course_variants
+        WHERE course_id = $1
+        ORDER BY distance_m ASC NULLS LAST, id ASC`
+
+	rows, err := m.DB.QueryContext(ctx, query, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	variants := []*Variant{}
+
+	for rows.Next() {
+		var variant Variant
+
+		err := rows.Scan(
+			&variant.ID,
+			&variant.CreatedAt,
+			&variant.LastUpdatedAt,
+			&variant.Version,
+			&variant.CourseID,
+			&variant.Name,
+			&variant.DistanceM,
+			pq.Array(&variant.Schedule.Days),
+			&variant.Schedule.StartTime,
+			&variant.Schedule.Notes,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		variants = append(variants, &variant)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return variants, nil
+}
+
+func (m VariantModel) Update(variant *Variant) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	route, err := marshalRoute(variant.Route)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE course_variants
+        SET name = $1, distance_m = $2, route = $3, days = $4, start_time = NULLIF($5, '')::time, schedule_notes = $6,
+            last_updated_at = now(), version = version + 1
+        WHERE id = $7 AND version = $8
+        RETURNING version, last_updated_at`
+
+	args := []any{
+		variant.Name,
+		variant.DistanceM,
+		route,
+		pq.Array(variant.Schedule.Days),
+		variant.Schedule.StartTime,
+		variant.Schedule.Notes,
+		variant.ID,
+		variant.Version,
+	}
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&variant.Version, &variant.LastUpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m VariantModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM course_variants WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// marshalRoute encodes a route for its jsonb column, which is NULL when the
+// variant has no route.
+func marshalRoute(route []Coords) ([]byte, error) {
+	if len(route) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(route)
+}
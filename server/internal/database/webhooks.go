@@ -0,0 +1,399 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type Webhook struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Version   int32     `json:"version"`
+	UserID    int64     `json:"user_id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	Events    []string  `json:"events"`
+}
+
+// WebhookDelivery is an event queued for, or sent to, a webhook. Together
+// they make up the webhook's delivery log.
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	WebhookID      int64      `json:"webhook_id"`
+	EventID        int64      `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// PendingDelivery is a claimed delivery with everything needed to send it.
+type PendingDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+	Event  Event
+}
+
+type WebhookModel struct {
+	DB *sqlx.DB
+}
+
+func (m WebhookModel) Insert(webhook *Webhook) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        INSERT INTO webhooks (user_id, url, secret, events)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at, version`
+
+	args := []any{webhook.UserID, webhook.URL, webhook.Secret, pq.Array(webhook.Events)}
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&webhook.ID, &webhook.CreatedAt, &webhook.Version)
+}
+
+func (m WebhookModel) Get(id int64) (*Webhook, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT id, created_at, version, user_id, url, secret, events
+        FROM webhooks
+        WHERE id = $1`
+
+	var webhook Webhook
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&webhook.ID,
+		&webhook.CreatedAt,
+		&webhook.Version,
+		&webhook.UserID,
+		&webhook.URL,
+		&webhook.Secret,
+		pq.Array(&webhook.Events),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &webhook, nil
+}
+
+func (m WebhookModel) GetAll() ([]*Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT id, created_at, version, user_id, url, secret, events
+        FROM webhooks
+        ORDER BY id`
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+
+	for rows.Next() {
+		var webhook Webhook
+
+		err := rows.Scan(
+			&webhook.ID,
+			&webhook.CreatedAt,
+			&webhook.Version,
+			&webhook.UserID,
+			&webhook.URL,
+			&webhook.Secret,
+			pq.Array(&webhook.Events),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, &webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (m WebhookModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetDeliveries returns a page of a webhook's delivery log, newest first.
+func (m WebhookModel) GetDeliveries(webhookID int64, filters Filters) ([]*WebhookDelivery, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        SELECT count(*) OVER(), d.id, d.created_at, d.webhook_id, d.event_id, e.type, d.status, d.attempts,
+            d.next_attempt_at, d.last_attempt_at, d.response_status, d.last_error
+        FROM webhook_deliveries d
+        INNER JOIN outbox_events e ON e.id = d.event_id
+        WHERE d.webhook_id = $1
+        ORDER BY d.id DESC
+        LIMIT $2 OFFSET $3`
+
+	rows, err := m.DB.QueryContext(ctx, query, webhookID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		var delivery WebhookDelivery
+
+		err := rows.Scan(
+			&totalRecords,
+			&delivery.ID,
+			&delivery.CreatedAt,
+			&delivery.WebhookID,
+			&delivery.EventID,
+			&delivery.EventType,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.LastAttemptAt,
+			&delivery.ResponseStatus,
+			&delivery.LastError,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		// The next attempt time is only meaningful while there is one.
+		if delivery.Status != DeliveryPending {
+			delivery.NextAttemptAt = nil
+		}
+
+		deliveries = append(deliveries, &delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return deliveries, metadata, nil
+}
+
+// QueueDeliveries takes up to limit events from the outbox that haven't been
+// dispatched yet, queues a delivery of each to every webhook subscribed to
+// its type, and marks them dispatched, all in one transaction. It returns how
+// many events were dispatched.
+func (m WebhookModel) QueueDeliveries(limit int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := m.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var eventIDs []int64
+
+	query := `
+        SELECT id
+        FROM outbox_events
+        WHERE dispatched_at IS NULL
+        ORDER BY id
+        LIMIT $1
+        FOR UPDATE SKIP LOCKED`
+
+	err = tx.SelectContext(ctx, &eventIDs, query, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(eventIDs) == 0 {
+		return 0, nil
+	}
+
+	query = `
+        INSERT INTO webhook_deliveries (webhook_id, event_id)
+        SELECT w.id, e.id
+        FROM outbox_events e
+        INNER JOIN webhooks w ON e.type = ANY(w.events)
+        WHERE e.id = ANY($1)
+        ORDER BY e.id, w.id`
+
+	_, err = tx.ExecContext(ctx, query, pq.Array(eventIDs))
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE outbox_events SET dispatched_at = now() WHERE id = ANY($1)`, pq.Array(eventIDs))
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+
+	return len(eventIDs), nil
+}
+
+// ClaimDeliveries picks up to limit pending deliveries that are due and
+// counts an attempt against each. Their next attempt is pushed back by lease,
+// so that if this process dies mid-send the delivery is retried once the
+// lease is up, rather than being lost or sent twice at the same time.
+func (m WebhookModel) ClaimDeliveries(limit int, lease time.Duration) ([]*PendingDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        UPDATE webhook_deliveries d
+        SET attempts = d.attempts + 1, next_attempt_at = now() + $2::double precision * interval '1 second'
+        FROM webhooks w, outbox_events e
+        WHERE d.id IN (
+            SELECT id
+            FROM webhook_deliveries
+            WHERE status = 'pending' AND next_attempt_at <= now()
+            ORDER BY next_attempt_at, id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        AND w.id = d.webhook_id
+        AND e.id = d.event_id
+        RETURNING d.id, d.created_at, d.webhook_id, d.attempts, w.url, w.secret, e.id, e.created_at, e.type, e.course_id, e.data`
+
+	rows, err := m.DB.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deliveries := []*PendingDelivery{}
+
+	for rows.Next() {
+		var delivery PendingDelivery
+		var data []byte
+
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.CreatedAt,
+			&delivery.WebhookID,
+			&delivery.Attempts,
+			&delivery.URL,
+			&delivery.Secret,
+			&delivery.Event.ID,
+			&delivery.Event.CreatedAt,
+			&delivery.Event.Type,
+			&delivery.Event.CourseID,
+			&data,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		delivery.Status = DeliveryPending
+		delivery.EventID = delivery.Event.ID
+		delivery.EventType = delivery.Event.Type
+		delivery.Event.Data = json.RawMessage(data)
+
+		deliveries = append(deliveries, &delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// RecordAttempt saves the outcome of sending a claimed delivery: its status,
+// response and, if it's still pending, when to try again.
+func (m WebhookModel) RecordAttempt(delivery *WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+        UPDATE webhook_deliveries
+        SET status = $1, response_status = $2, last_error = $3, last_attempt_at = now(),
+            next_attempt_at = COALESCE($4, next_attempt_at)
+        WHERE id = $5
+        RETURNING last_attempt_at`
+
+	args := []any{
+		delivery.Status,
+		delivery.ResponseStatus,
+		delivery.LastError,
+		delivery.NextAttemptAt,
+		delivery.ID,
+	}
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&delivery.LastAttemptAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
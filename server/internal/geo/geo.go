@@ -0,0 +1,271 @@
+// Package geo works out which country, region and timezone a point is in,
+// using boundaries embedded in the binary rather than a network geocoder.
+package geo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"peterweightman.com/runda/assets"
+
+	// The timezone database is built in, so that timezones work the same
+	// on hosts without one installed.
+	_ "time/tzdata"
+)
+
+// Place is where a point is. Region is the first-level subdivision, such as a
+// state or a country of the UK, and is empty for countries that the dataset
+// doesn't divide. Both are empty for points outside every boundary.
+type Place struct {
+	CountryCode string `json:"country_code"`
+	Region      string `json:"region"`
+}
+
+// Index finds the boundary containing a point. Boundaries shouldn't overlap,
+// but where they do, the first one in the dataset wins.
+type Index struct {
+	features []feature
+}
+
+// TimezoneIndex finds the IANA timezone of a point.
+type TimezoneIndex struct {
+	index *Index
+}
+
+type feature struct {
+	properties map[string]string
+	// Each polygon is a list of rings, the first being its outline and any
+	// others holes in it.
+	polygons [][][]point
+	bbox     bbox
+}
+
+type point struct {
+	lon, lat float64
+}
+
+type bbox struct {
+	minLon, minLat, maxLon, maxLat float64
+}
+
+func (b bbox) contains(p point) bool {
+	return p.lon >= b.minLon && p.lon <= b.maxLon && p.lat >= b.minLat && p.lat <= b.maxLat
+}
+
+// Parse reads a GeoJSON FeatureCollection of Polygon and MultiPolygon
+// features, each with country_code and region properties.
+func Parse(data []byte) (*Index, error) {
+	return parse(data, func(properties map[string]string) error {
+		if len(properties["country_code"]) != 2 {
+			return errors.New("country_code must be two letters")
+		}
+		return nil
+	})
+}
+
+// ParseTimezones reads a GeoJSON FeatureCollection like Parse, but with a
+// tzid property on each feature naming its timezone, such as Europe/London.
+func ParseTimezones(data []byte) (*TimezoneIndex, error) {
+	index, err := parse(data, func(properties map[string]string) error {
+		if !IsTimezone(properties["tzid"]) {
+			return fmt.Errorf("unknown timezone %q", properties["tzid"])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TimezoneIndex{index: index}, nil
+}
+
+// IsTimezone reports whether name is a timezone in the IANA database, as
+// opposed to Local or an empty string, which time.LoadLocation also accepts.
+func IsTimezone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+func parse(data []byte, check func(map[string]string) error) (*Index, error) {
+	var collection struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Properties map[string]string `json:"properties"`
+			Geometry   struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+
+	err := json.Unmarshal(data, &collection)
+	if err != nil {
+		return nil, err
+	}
+
+	if collection.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("expected a FeatureCollection, got %q", collection.Type)
+	}
+
+	index := &Index{}
+
+	for i, f := range collection.Features {
+		var polygons [][][][2]float64
+
+		switch f.Geometry.Type {
+		case "Polygon":
+			var polygon [][][2]float64
+			err = json.Unmarshal(f.Geometry.Coordinates, &polygon)
+			polygons = append(polygons, polygon)
+		case "MultiPolygon":
+			err = json.Unmarshal(f.Geometry.Coordinates, &polygons)
+		default:
+			err = fmt.Errorf("unsupported geometry type %q", f.Geometry.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %w", i, err)
+		}
+
+		err = check(f.Properties)
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %w", i, err)
+		}
+
+		index.features = append(index.features, newFeature(f.Properties, polygons))
+	}
+
+	return index, nil
+}
+
+func newFeature(properties map[string]string, coordinates [][][][2]float64) feature {
+	f := feature{
+		properties: properties,
+		bbox:       bbox{minLon: 180, minLat: 90, maxLon: -180, maxLat: -90},
+	}
+
+	for _, polygon := range coordinates {
+		rings := make([][]point, 0, len(polygon))
+
+		for _, ring := range polygon {
+			points := make([]point, len(ring))
+
+			for i, c := range ring {
+				points[i] = point{lon: c[0], lat: c[1]}
+
+				f.bbox.minLon = min(f.bbox.minLon, c[0])
+				f.bbox.minLat = min(f.bbox.minLat, c[1])
+				f.bbox.maxLon = max(f.bbox.maxLon, c[0])
+				f.bbox.maxLat = max(f.bbox.maxLat, c[1])
+			}
+
+			rings = append(rings, points)
+		}
+
+		f.polygons = append(f.polygons, rings)
+	}
+
+	return f
+}
+
+// Lookup returns the place containing the point, or an empty Place if it's
+// outside every boundary, such as at sea.
+func (idx *Index) Lookup(longitude, latitude float64) Place {
+	properties := idx.find(longitude, latitude)
+
+	return Place{CountryCode: properties["country_code"], Region: properties["region"]}
+}
+
+// Lookup returns the name of the timezone containing the point, or an empty
+// string if it's outside every boundary.
+func (idx *TimezoneIndex) Lookup(longitude, latitude float64) string {
+	return idx.index.find(longitude, latitude)["tzid"]
+}
+
+// find returns the properties of the first feature containing the point, or
+// nil if there isn't one.
+func (idx *Index) find(longitude, latitude float64) map[string]string {
+	p := point{lon: longitude, lat: latitude}
+
+	for _, f := range idx.features {
+		if !f.bbox.contains(p) {
+			continue
+		}
+
+		for _, polygon := range f.polygons {
+			if inPolygon(p, polygon) {
+				return f.properties
+			}
+		}
+	}
+
+	return nil
+}
+
+// inPolygon reports whether p is inside the polygon's outline and not in any
+// of its holes. Counting crossings over every ring together gives the same
+// answer, as long as the holes are inside the outline.
+func inPolygon(p point, rings [][]point) bool {
+	inside := false
+
+	for _, ring := range rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+
+			if (a.lat > p.lat) != (b.lat > p.lat) && p.lon < (b.lon-a.lon)*(p.lat-a.lat)/(b.lat-a.lat)+a.lon {
+				inside = !inside
+			}
+		}
+	}
+
+	return inside
+}
+
+var (
+	defaultOnce  sync.Once
+	defaultIndex *Index
+
+	timezonesOnce  sync.Once
+	timezonesIndex *TimezoneIndex
+)
+
+// Default returns the index of the boundaries in assets/geo, which is built
+// the first time it's needed. It panics if the embedded file is invalid, as
+// that can only be a mistake in the build.
+func Default() *Index {
+	defaultOnce.Do(func() {
+		defaultIndex = mustLoad("boundaries.geojson", Parse)
+	})
+
+	return defaultIndex
+}
+
+// Timezones returns the index of the timezones in assets/geo. Like Default,
+// it's built when first needed and panics if the file is invalid.
+func Timezones() *TimezoneIndex {
+	timezonesOnce.Do(func() {
+		timezonesIndex = mustLoad("timezones.geojson", ParseTimezones)
+	})
+
+	return timezonesIndex
+}
+
+func mustLoad[T any](name string, parse func([]byte) (T, error)) T {
+	data, err := assets.EmbeddedFiles.ReadFile("geo/" + name)
+	if err != nil {
+		panic(err)
+	}
+
+	index, err := parse(data)
+	if err != nil {
+		panic(fmt.Sprintf("geo: invalid %s: %s", name, err))
+	}
+
+	return index
+}
@@ -0,0 +1,175 @@
+// Package openapi reads the API's OpenAPI document and checks requests
+// against it. It understands only the parts of OpenAPI 3.1 and JSON Schema
+// that our own document uses.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type Document struct {
+	Paths      map[string]*PathItem `json:"paths"`
+	Components struct {
+		Schemas    map[string]*Schema    `json:"schemas"`
+		Parameters map[string]*Parameter `json:"parameters"`
+	} `json:"components"`
+}
+
+type PathItem struct {
+	Parameters []*Parameter `json:"parameters"`
+	Get        *Operation   `json:"get"`
+	Post       *Operation   `json:"post"`
+	Put        *Operation   `json:"put"`
+	Patch      *Operation   `json:"patch"`
+	Delete     *Operation   `json:"delete"`
+}
+
+func (p *PathItem) operations() map[string]*Operation {
+	ops := map[string]*Operation{
+		http.MethodGet:    p.Get,
+		http.MethodPost:   p.Post,
+		http.MethodPut:    p.Put,
+		http.MethodPatch:  p.Patch,
+		http.MethodDelete: p.Delete,
+	}
+
+	for method, op := range ops {
+		if op == nil {
+			delete(ops, method)
+		}
+	}
+
+	return ops
+}
+
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	Parameters  []*Parameter `json:"parameters"`
+	RequestBody *RequestBody `json:"requestBody"`
+
+	// params holds the path item's parameters merged with the operation's own.
+	params []*Parameter
+}
+
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref        string             `json:"$ref"`
+	Type       schemaType         `json:"type"`
+	Properties map[string]*Schema `json:"properties"`
+	Required   []string           `json:"required"`
+	Items      *Schema            `json:"items"`
+	OneOf      []*Schema          `json:"oneOf"`
+	Enum       []any              `json:"enum"`
+	Minimum    *float64           `json:"minimum"`
+	Maximum    *float64           `json:"maximum"`
+	MinLength  *int               `json:"minLength"`
+	MaxLength  *int               `json:"maxLength"`
+}
+
+// schemaType holds a JSON Schema type, which may be a single type or a list
+// of allowed types.
+type schemaType []string
+
+func (t *schemaType) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*t = schemaType{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+
+	*t = list
+	return nil
+}
+
+type Route struct {
+	Method string
+	Path   string
+}
+
+// Load parses an OpenAPI document and resolves its parameter references.
+func Load(data []byte) (*Document, error) {
+	var doc Document
+
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for path, item := range doc.Paths {
+		for method, op := range item.operations() {
+			params := append(append([]*Parameter{}, item.Parameters...), op.Parameters...)
+
+			for i, param := range params {
+				if param.Ref == "" {
+					continue
+				}
+
+				resolved, ok := doc.Components.Parameters[strings.TrimPrefix(param.Ref, "#/components/parameters/")]
+				if !ok {
+					return nil, fmt.Errorf("%s %s: unknown parameter %s", method, path, param.Ref)
+				}
+				params[i] = resolved
+			}
+
+			op.params = params
+		}
+	}
+
+	return &doc, nil
+}
+
+// Routes returns every method and path in the document, sorted by path.
+func (d *Document) Routes() []Route {
+	routes := []Route{}
+
+	for path, item := range d.Paths {
+		for method := range item.operations() {
+			routes = append(routes, Route{Method: method, Path: path})
+		}
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	return routes
+}
+
+// Operation returns the operation for a method and templated path, such as
+// "/v1/courses/{id}", or nil if the document doesn't have it.
+func (d *Document) Operation(method, path string) *Operation {
+	item, ok := d.Paths[path]
+	if !ok {
+		return nil
+	}
+
+	return item.operations()[method]
+}
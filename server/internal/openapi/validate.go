@@ -0,0 +1,229 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidateRequest checks the parameters and JSON body of a request against
+// the operation. pathParams holds the values of the path's template
+// variables, and body the request body if it is JSON. It returns a
+// description of each problem found, or nil if the request is valid. Bodies
+// other than JSON only have their media type checked.
+func (d *Document) ValidateRequest(op *Operation, r *http.Request, pathParams map[string]string, body []byte) []string {
+	problems := []string{}
+
+	for _, param := range op.params {
+		var value string
+		var present bool
+
+		switch param.In {
+		case "path":
+			value, present = pathParams[param.Name]
+		case "query":
+			present = r.URL.Query().Has(param.Name)
+			value = r.URL.Query().Get(param.Name)
+		case "header":
+			value = r.Header.Get(param.Name)
+			present = value != ""
+		default:
+			continue
+		}
+
+		if !present {
+			if param.Required {
+				problems = append(problems, fmt.Sprintf("%s parameter %q is required", param.In, param.Name))
+			}
+			continue
+		}
+
+		if param.Schema != nil {
+			problems = append(problems, d.validateParam(param, value)...)
+		}
+	}
+
+	if op.RequestBody == nil {
+		return nilIfEmpty(problems)
+	}
+
+	// A ContentLength of -1 means the length is unknown, not that there's no
+	// body.
+	if len(body) == 0 && r.ContentLength == 0 {
+		if op.RequestBody.Required {
+			problems = append(problems, "request body is required")
+		}
+		return nilIfEmpty(problems)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	content, ok := op.RequestBody.Content[mediaType]
+	if !ok {
+		problems = append(problems, fmt.Sprintf("request body must not be sent as %q", mediaType))
+		return nilIfEmpty(problems)
+	}
+
+	if mediaType != "application/json" || content.Schema == nil {
+		return nilIfEmpty(problems)
+	}
+
+	var value any
+
+	err := json.Unmarshal(body, &value)
+	if err != nil {
+		problems = append(problems, "request body must be valid JSON")
+		return problems
+	}
+
+	problems = append(problems, d.validate(content.Schema, value, "body")...)
+	return nilIfEmpty(problems)
+}
+
+// validateParam converts a parameter from its string form to the type its
+// schema expects before validating it.
+func (d *Document) validateParam(param *Parameter, raw string) []string {
+	name := fmt.Sprintf("%s parameter %q", param.In, param.Name)
+	schema := d.resolve(param.Schema)
+
+	var value any = raw
+
+	switch {
+	case slices.Contains(schema.Type, "integer"):
+		i, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return []string{name + " must be an integer"}
+		}
+		value = float64(i)
+	case slices.Contains(schema.Type, "number"):
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return []string{name + " must be a number"}
+		}
+		value = f
+	case slices.Contains(schema.Type, "boolean"):
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return []string{name + " must be true or false"}
+		}
+		value = b
+	}
+
+	return d.validate(schema, value, name)
+}
+
+func (d *Document) resolve(schema *Schema) *Schema {
+	for schema.Ref != "" {
+		schema = d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if schema == nil {
+			return &Schema{}
+		}
+	}
+
+	return schema
+}
+
+func (d *Document) validate(schema *Schema, value any, at string) []string {
+	schema = d.resolve(schema)
+
+	if len(schema.OneOf) > 0 {
+		for _, option := range schema.OneOf {
+			if len(d.validate(option, value, at)) == 0 {
+				return nil
+			}
+		}
+		return []string{at + " doesn't match any of the allowed shapes"}
+	}
+
+	if len(schema.Type) > 0 && !slices.ContainsFunc(schema.Type, func(t string) bool { return isType(value, t) }) {
+		return []string{fmt.Sprintf("%s must be of type %s", at, strings.Join(schema.Type, " or "))}
+	}
+
+	if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, value) {
+		return []string{fmt.Sprintf("%s must be one of %v", at, schema.Enum)}
+	}
+
+	problems := []string{}
+
+	switch v := value.(type) {
+	case float64:
+		if schema.Minimum != nil && v < *schema.Minimum {
+			problems = append(problems, fmt.Sprintf("%s must be at least %v", at, *schema.Minimum))
+		}
+		if schema.Maximum != nil && v > *schema.Maximum {
+			problems = append(problems, fmt.Sprintf("%s must be at most %v", at, *schema.Maximum))
+		}
+
+	case string:
+		n := utf8.RuneCountInString(v)
+		if schema.MinLength != nil && n < *schema.MinLength {
+			problems = append(problems, fmt.Sprintf("%s must be at least %d characters long", at, *schema.MinLength))
+		}
+		if schema.MaxLength != nil && n > *schema.MaxLength {
+			problems = append(problems, fmt.Sprintf("%s must be at most %d characters long", at, *schema.MaxLength))
+		}
+
+	case []any:
+		if schema.Items != nil {
+			for i, item := range v {
+				problems = append(problems, d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+
+	case map[string]any:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s.%s is required", at, name))
+			}
+		}
+
+		for name, property := range schema.Properties {
+			if propertyValue, ok := v[name]; ok {
+				problems = append(problems, d.validate(property, propertyValue, at+"."+name)...)
+			}
+		}
+	}
+
+	return problems
+}
+
+func isType(value any, t string) bool {
+	switch t {
+	case "null":
+		return value == nil
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	default:
+		return true
+	}
+}
+
+func nilIfEmpty(problems []string) []string {
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return problems
+}
@@ -0,0 +1,227 @@
+// Package stream fans course events out to any number of subscribers, such as
+// server-sent event connections, as they're committed on any API replica.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"peterweightman.com/runda/internal/database"
+)
+
+const (
+	// fetchSize is how many events are read from the outbox at a time.
+	fetchSize = 500
+
+	// pollInterval is how often the outbox is checked even without a
+	// notification, in case one was lost while the listener reconnected.
+	pollInterval = 30 * time.Second
+
+	// maxRetryWait caps the backoff between attempts to start listening.
+	maxRetryWait = time.Minute
+
+	// bufferSize is how many events a subscriber can fall behind by before
+	// it's dropped. A dropped subscriber can reconnect and catch up from the
+	// outbox, so it's better than holding up everyone else.
+	bufferSize = 256
+)
+
+// Event is an outbox event along with the course it's about, decoded once for
+// all subscribers. Course only has its ID set for deletions. Previous is only
+// set for updates that could have moved the course or changed its tags.
+type Event struct {
+	*database.Event
+	Course   database.Course
+	Previous *database.PreviousCourse
+}
+
+type Subscription struct {
+	// C receives events in ID order. It's closed when the subscriber falls too
+	// far behind or the broker stops.
+	C chan *Event
+}
+
+// Broker listens for event notifications from Postgres and passes each new
+// event on to every subscriber. The notifications only say that there's
+// something new; the events themselves are read from the outbox, in order,
+// so nothing is skipped even if notifications are lost.
+type Broker struct {
+	Events database.EventModel
+	DSN    string
+	Logger *slog.Logger
+
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	stopped bool
+	lastID  int64
+}
+
+// Subscribe starts passing new events to the returned subscription. Events
+// already in the outbox aren't sent; read those with EventModel.GetAfter.
+func (b *Broker) Subscribe() *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := &Subscription{C: make(chan *Event, bufferSize)}
+
+	if b.stopped {
+		close(sub.C)
+		return sub
+	}
+
+	if b.subs == nil {
+		b.subs = make(map[*Subscription]struct{})
+	}
+	b.subs[sub] = struct{}{}
+
+	return sub
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.C)
+	}
+}
+
+// Run listens until ctx is done, then closes every subscription. If the
+// database can't be reached to start with, it keeps trying with backoff
+// rather than leaving streams without events until the process restarts.
+func (b *Broker) Run(ctx context.Context) {
+	defer b.stop()
+
+	listener := b.listen(ctx)
+	if listener == nil {
+		return
+	}
+	defer listener.Close()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		// A nil notification means the listener has reconnected, and may have
+		// missed some, so it's treated the same as a real one.
+		case <-listener.Notify:
+		case <-ticker.C:
+		}
+
+		b.fetch()
+	}
+}
+
+// listen starts listening for notifications from the latest event on,
+// retrying until it succeeds. It returns nil if ctx is done first.
+func (b *Broker) listen(ctx context.Context) *pq.Listener {
+	wait := time.Second
+
+	for {
+		listener, err := b.startListener()
+		if err == nil {
+			return listener
+		}
+
+		b.Logger.Error("Error starting event listener, will retry", "error", err, "retry_in", wait)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+
+		wait = min(wait*2, maxRetryWait)
+	}
+}
+
+func (b *Broker) startListener() (*pq.Listener, error) {
+	lastID, err := b.Events.LatestID()
+	if err != nil {
+		return nil, err
+	}
+
+	listener := pq.NewListener(b.DSN, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			b.Logger.Error("Event listener connection problem", "error", err)
+		}
+	})
+
+	err = listener.Listen(database.EventsChannel)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	b.lastID = lastID
+
+	return listener, nil
+}
+
+// fetch reads everything after the last event seen and broadcasts it.
+func (b *Broker) fetch() {
+	for {
+		events, err := b.Events.GetAfter(b.lastID, fetchSize)
+		if err != nil {
+			b.Logger.Error("Error reading events", "error", err)
+			return
+		}
+
+		for _, event := range events {
+			b.broadcast(Decode(event))
+			b.lastID = event.ID
+		}
+
+		if len(events) < fetchSize {
+			return
+		}
+	}
+}
+
+func (b *Broker) broadcast(event *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subs {
+		select {
+		case sub.C <- event:
+		default:
+			delete(b.subs, sub)
+			close(sub.C)
+		}
+	}
+}
+
+func (b *Broker) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stopped = true
+
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.C)
+	}
+}
+
+// Decode pulls the course out of an event's data. An event that can't be
+// decoded still carries its course ID, so it's passed on rather than lost.
+func Decode(event *database.Event) *Event {
+	var data struct {
+		Course   database.Course          `json:"course"`
+		Previous *database.PreviousCourse `json:"previous"`
+	}
+
+	_ = json.Unmarshal(event.Data, &data)
+	data.Course.ID = event.CourseID
+
+	return &Event{Event: event, Course: data.Course, Previous: data.Previous}
+}
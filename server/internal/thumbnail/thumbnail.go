@@ -0,0 +1,137 @@
+package thumbnail
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+
+	_ "image/gif"
+	_ "image/png"
+)
+
+type Size struct {
+	Name    string
+	MaxSide int
+}
+
+var Sizes = []Size{
+	{Name: "small", MaxSide: 160},
+	{Name: "medium", MaxSide: 640},
+	{Name: "large", MaxSide: 1280},
+}
+
+// MaxPixels is the largest image, in width times height, that Decode will
+// read. A few kilobytes of compressed data can claim to be an enormous image,
+// which would otherwise be decoded in full.
+const MaxPixels = 25_000_000
+
+var ErrTooLarge = errors.New("image too large")
+
+// Decode reads a JPEG, PNG or GIF image into RGBA, ready for Fit. The size is
+// read from the header first, and images with more than MaxPixels return
+// ErrTooLarge without being decoded.
+func Decode(r io.ReadSeeker) (*image.RGBA, error) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(config.Width)*int64(config.Height) > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return toRGBA(img), nil
+}
+
+// EncodeJPEG writes img to w as a JPEG, which all thumbnails are stored as.
+// JPEG has no alpha channel, so transparent areas are flattened onto white.
+func EncodeJPEG(w io.Writer, img image.Image) error {
+	flattened := image.NewRGBA(img.Bounds())
+	draw.Draw(flattened, flattened.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flattened, flattened.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	return jpeg.Encode(w, flattened, &jpeg.Options{Quality: 85})
+}
+
+// Fit scales src down so that neither side is longer than maxSide, keeping its
+// aspect ratio. Images that already fit are returned at their original size.
+func Fit(src *image.RGBA, maxSide int) *image.RGBA {
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+
+	if width <= maxSide && height <= maxSide {
+		return src
+	}
+
+	if width >= height {
+		height = max(1, height*maxSide/width)
+		width = maxSide
+	} else {
+		width = max(1, width*maxSide/height)
+		height = maxSide
+	}
+
+	return resize(src, width, height)
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+
+	return dst
+}
+
+// resize downsamples src using a box filter, averaging every source pixel
+// that falls within each destination pixel. It's only used for shrinking
+// images, where it gives much smoother results than nearest neighbour.
+func resize(src *image.RGBA, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	srcWidth, srcHeight := src.Rect.Dx(), src.Rect.Dy()
+
+	for y := 0; y < height; y++ {
+		y0 := y * srcHeight / height
+		y1 := max(y0+1, (y+1)*srcHeight/height)
+
+		for x := 0; x < width; x++ {
+			x0 := x * srcWidth / width
+			x1 := max(x0+1, (x+1)*srcWidth/width)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					b += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
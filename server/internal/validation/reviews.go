@@ -0,0 +1,38 @@
+package validation
+
+import (
+	"errors"
+
+	"peterweightman.com/runda/internal/database"
+)
+
+var (
+	ErrRatingOutOfRange = errors.New("rating must be between 1 and 5")
+	ErrReviewTextBlank  = errors.New("review text must be provided")
+	ErrReviewTooShort   = errors.New("review text too short")
+	ErrReviewTooLong    = errors.New("review text too long")
+)
+
+var (
+	MinRating         = 1
+	MaxRating         = 5
+	MinReviewTextSize = 10
+	MaxReviewTextSize = 5_000
+)
+
+func ValidateReview(r *database.Review) error {
+	if !Between(r.Rating, MinRating, MaxRating) {
+		return ErrRatingOutOfRange
+	}
+	if !NotBlank(r.Text) {
+		return ErrReviewTextBlank
+	}
+	if !MinRunes(r.Text, MinReviewTextSize) {
+		return ErrReviewTooShort
+	}
+	if !MaxRunes(r.Text, MaxReviewTextSize) {
+		return ErrReviewTooLong
+	}
+
+	return nil
+}
@@ -0,0 +1,12 @@
+package validation
+
+import (
+	"github.com/go-playground/validator/v10"
+	"peterweightman.com/runda/internal/geo"
+)
+
+// validateTimezone accepts IANA timezone names. Unlike the built-in timezone
+// tag, it rejects Local, which would mean whatever the server is set to.
+func validateTimezone(fl validator.FieldLevel) bool {
+	return geo.IsTimezone(fl.Field().String())
+}
@@ -5,6 +5,7 @@ import "github.com/go-playground/validator/v10"
 func NewValidator() *validator.Validate {
 	v := validator.New()
 	v.RegisterValidation("optional_uri", validateOptionalURI)
+	v.RegisterValidation("iana_timezone", validateTimezone)
 
 	return v
 }
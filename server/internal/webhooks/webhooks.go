@@ -0,0 +1,245 @@
+// Package webhooks sends course events from the outbox to the webhooks
+// subscribed to them.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"peterweightman.com/runda/internal/database"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it's marked
+	// failed. With the backoff below, the last attempt is a little over four
+	// hours after the first.
+	MaxAttempts = 10
+
+	// queueSize is how many outbox events are turned into deliveries at a
+	// time, and claimSize how many deliveries are claimed and sent at once.
+	queueSize = 50
+	claimSize = 10
+
+	// lease is how long a claimed delivery is left alone before another
+	// dispatcher may retry it. Every send has to finish within half of it.
+	lease = time.Minute
+
+	// pruneInterval is how often old events are pruned from the outbox, and
+	// pruneSize how many are deleted at a time.
+	pruneInterval = time.Hour
+	pruneSize     = 1000
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderEvent     = "X-Runda-Event"
+	HeaderDelivery  = "X-Runda-Delivery"
+	HeaderTimestamp = "X-Runda-Timestamp"
+	HeaderSignature = "X-Runda-Signature"
+)
+
+// Sign returns the signature header value for a delivery body: the hex
+// HMAC-SHA256, keyed with the webhook's secret, of the timestamp header
+// value, a full stop and the body. Receivers should compute the same and
+// compare in constant time, and reject old timestamps to stop replays.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff is how long to wait before the next attempt after the given number
+// of failed attempts: 30 seconds, doubling each time up to 6 hours.
+func Backoff(attempts int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempts && d < 6*time.Hour; i++ {
+		d *= 2
+	}
+
+	return min(d, 6*time.Hour)
+}
+
+// Dispatcher moves events from the outbox into per-webhook deliveries and
+// sends them. Several dispatchers can run against the same database, as rows
+// are claimed with SKIP LOCKED.
+type Dispatcher struct {
+	Webhooks  database.WebhookModel
+	Events    database.EventModel
+	Client    *http.Client
+	Logger    *slog.Logger
+	UserAgent string
+	// Interval is how long to sleep when there's nothing to do.
+	Interval time.Duration
+	// Retention is how long sent events and their deliveries are kept.
+	// They're kept forever if it's zero.
+	Retention time.Duration
+}
+
+// Run dispatches until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var lastPrune time.Time
+
+	for {
+		if d.Retention > 0 && time.Since(lastPrune) >= pruneInterval {
+			d.prune()
+			lastPrune = time.Now()
+		}
+
+		busy := d.runOnce(ctx)
+		if busy {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.Interval):
+		}
+	}
+}
+
+// runOnce reports whether there was work, in which case there may be more
+// waiting and Run goes straight round again.
+func (d *Dispatcher) runOnce(ctx context.Context) bool {
+	queued, err := d.Webhooks.QueueDeliveries(queueSize)
+	if err != nil {
+		d.Logger.Error("Error queueing webhook deliveries", "error", err)
+		return false
+	}
+
+	claimed := time.Now()
+
+	deliveries, err := d.Webhooks.ClaimDeliveries(claimSize, lease)
+	if err != nil {
+		d.Logger.Error("Error claiming webhook deliveries", "error", err)
+		return false
+	}
+
+	// The batch is sent all at once, and every send is cut off half a lease
+	// after the claim, so each attempt is recorded well before another
+	// dispatcher could claim the delivery again.
+	ctx, cancel := context.WithDeadline(ctx, claimed.Add(lease/2))
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery *database.PendingDelivery) {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+
+	return queued > 0 || len(deliveries) > 0
+}
+
+// prune deletes events older than the retention period from the outbox, a
+// batch at a time so as not to hold locks for long.
+func (d *Dispatcher) prune() {
+	total := 0
+
+	for {
+		n, err := d.Events.Prune(d.Retention, pruneSize)
+		if err != nil {
+			d.Logger.Error("Error pruning outbox events", "error", err)
+			return
+		}
+
+		total += n
+		if n < pruneSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		d.Logger.Info("Pruned outbox events", "count", total)
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery *database.PendingDelivery) {
+	logger := d.Logger.With("webhook_id", delivery.WebhookID, "delivery_id", delivery.ID, "attempt", delivery.Attempts)
+
+	status, err := d.send(ctx, delivery)
+
+	result := delivery.WebhookDelivery
+	result.ResponseStatus = status
+	result.LastError = ""
+
+	switch {
+	case err == nil:
+		result.Status = database.DeliverySucceeded
+	case delivery.Attempts >= MaxAttempts:
+		result.Status = database.DeliveryFailed
+		result.LastError = err.Error()
+		logger.Warn("Webhook delivery failed, giving up", "error", err)
+	default:
+		next := time.Now().Add(Backoff(delivery.Attempts))
+		result.NextAttemptAt = &next
+		result.LastError = err.Error()
+		logger.Info("Webhook delivery failed, will retry", "error", err, "next_attempt_at", next)
+	}
+
+	err = d.Webhooks.RecordAttempt(&result)
+	if err != nil {
+		logger.Error("Error recording webhook delivery attempt", "error", err)
+	}
+}
+
+// send posts the event to the webhook, returning the response status if
+// there was one. Anything other than a 2xx response is an error.
+func (d *Dispatcher) send(ctx context.Context, delivery *database.PendingDelivery) (int, error) {
+	// The body is the event as stored, e.g.
+	//
+	//	{"id": 42, "type": "course.updated", "created_at": "...", "data": {"course": {...}}}
+	//
+	// The id is the same for every delivery of an event, so receivers can use
+	// it to ignore repeats.
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", d.UserAgent)
+	req.Header.Set(HeaderEvent, delivery.Event.Type)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// Drain a little of the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected response status %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}